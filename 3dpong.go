@@ -10,9 +10,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
-	"github.com/qeedquan/go-media/image/ttf"
 	"github.com/qeedquan/go-media/math/ga"
 	"github.com/qeedquan/go-media/math/ga/vec2"
 	"github.com/qeedquan/go-media/math/ga/vec3"
@@ -26,6 +26,9 @@ const (
 	HANDBALL = iota
 	ONE_PLAYER
 	TWO_PLAYERS
+	FOUR_PLAYERS
+	DOUBLES
+	PRACTICE
 )
 
 var (
@@ -44,46 +47,143 @@ func main() {
 func usage() {
 	fmt.Fprintln(os.Stderr, "usage: 3dpong [options]")
 	flag.PrintDefaults()
-	fmt.Fprintln(os.Stderr, "Controls:")
-	fmt.Fprintln(os.Stderr, "    [V] - Change View")
-	fmt.Fprintln(os.Stderr, "    [3] Toggle 3D glasses mode")
-	fmt.Fprintln(os.Stderr, "    [C] Toggle \"noclick\" mode")
-	fmt.Fprintln(os.Stderr, "    [R] Reset the game")
-	fmt.Fprintln(os.Stderr, "    [Q] Quit")
+	err := game.LoadKeyMap()
+	if err != nil && !os.IsNotExist(err) {
+		ek(err)
+	}
+	usageControls()
 
 	os.Exit(2)
 }
 
 func parseFlags() {
+	f := &game.Config
 	game.Assets = filepath.Join(sdl.GetBasePath(), "assets")
 	flag.StringVar(&game.Assets, "assets", game.Assets, "assets directory")
-	flag.Float64Var(&game.Net, "net", game.Net, "size of net")
-	flag.Float64Var(&game.Gravity, "gravity", game.Gravity, "gravity")
-	flag.BoolVar(&game.NoClick[0], "noclick1", game.NoClick[0], "no click for player 1")
-	flag.BoolVar(&game.NoClick[1], "noclick2", game.NoClick[1], "no click for player 2")
-	flag.BoolVar(&game.Fullscreen, "fullscreen", game.Fullscreen, "fullscreen mode")
-	flag.BoolVar(&game.Sound, "sound", game.Sound, "sound")
-	flag.IntVar(&game.Mode, "mode", game.Mode, "game mode (0: handball, 1: one player, 2: two player)")
+	flag.StringVar(&game.ConfigFile, "config", game.ConfigFile, "config file")
+	flag.StringVar(&game.KeyMapFile, "keymap", game.KeyMapFile, "key map file")
+	flag.StringVar(&game.HighScoreFile, "highscores", game.HighScoreFile, "high score file")
+	flag.BoolVar(&game.DumpConfig, "dump-config", game.DumpConfig, "print the effective config and exit")
+	flag.BoolVar(&game.Menu.Title, "menu", game.Menu.Title, "start at the title menu")
+	flag.Float64Var(&f.Net, "net", f.Net, "size of net")
+	flag.Float64Var(&f.Gravity, "gravity", f.Gravity, "gravity")
+	flag.IntVar(&f.GravityMode, "gravity-mode", f.GravityMode, "which way gravity pulls (0: classic, 1: vector, 2: zero-g, 3: rotating)")
+	flag.Float64Var(&f.GravityDir.X, "gravity-x", f.GravityDir.X, "x direction of gravity in vector mode")
+	flag.Float64Var(&f.GravityDir.Y, "gravity-y", f.GravityDir.Y, "y direction of gravity in vector mode")
+	flag.Float64Var(&f.GravityDir.Z, "gravity-z", f.GravityDir.Z, "z direction of gravity in vector mode")
+	flag.Float64Var(&f.GravityPeriod, "gravity-period", f.GravityPeriod, "seconds between turns of rotating gravity")
+	flag.IntVar(&f.Drill, "drill", f.Drill, "where the launcher aims in practice mode (0: random, 1: alternating corners, 2: scripted)")
+	flag.Float64Var(&f.LaunchSpeed, "launch-speed", f.LaunchSpeed, "speed of the balls from the launcher")
+	flag.Float64Var(&f.LaunchSpin.X, "launch-spin-x", f.LaunchSpin.X, "sideways spin of the balls from the launcher")
+	flag.Float64Var(&f.LaunchSpin.Y, "launch-spin-y", f.LaunchSpin.Y, "up and down spin of the balls from the launcher")
+	flag.Float64Var(&f.LaunchHeight, "launch-height", f.LaunchHeight, "height of the launcher from -1 (bottom) to 1 (top)")
+	flag.Float64Var(&f.LaunchInterval, "launch-interval", f.LaunchInterval, "seconds between balls from the launcher")
+	flag.IntVar(&f.Challenge, "challenge", f.Challenge, "handball challenge (0: classic, 1: targets, 2: moving targets, 3: timed)")
+	flag.IntVar(&f.Targets, "targets", f.Targets, "number of targets on the back wall in handball challenges")
+	flag.Float64Var(&f.TargetSize, "target-size", f.TargetSize, "radius of the targets")
+	flag.Float64Var(&f.TargetSpeed, "target-speed", f.TargetSpeed, "speed of moving targets")
+	flag.IntVar(&f.TargetPoints, "target-points", f.TargetPoints, "bonus points for a target, times the combo")
+	flag.Float64Var(&f.ChallengeTime, "challenge-time", f.ChallengeTime, "seconds a game of time attack or the timed challenge lasts")
+	flag.IntVar(&f.Variant, "variant", f.Variant, "handball rules (0: classic, 1: survival, 2: time attack, 3: sudden death)")
+	flag.BoolVar(&f.NoClick[0], "noclick1", f.NoClick[0], "no click for player 1")
+	flag.BoolVar(&f.NoClick[1], "noclick2", f.NoClick[1], "no click for player 2")
+	flag.BoolVar(&f.Fullscreen, "fullscreen", f.Fullscreen, "fullscreen mode")
+	flag.BoolVar(&f.Sound, "sound", f.Sound, "sound")
+	flag.IntVar(&f.Mode, "mode", f.Mode, "game mode (0: handball, 1: one player, 2: two player, 3: four player, 4: doubles, 5: practice)")
+	flag.Float64Var(&f.DoublesGap, "doubles-gap", f.DoublesGap, "distance of the front paddles from the ends in doubles")
+	flag.Var((*seatsFlag)(&f.Seats), "seats", "players of the four seats in four player mode and doubles ("+strings.Join(seatTypeNames[:], ", ")+")")
+	flag.IntVar(&f.Lives, "lives", f.Lives, "lives of each player in four player mode")
+	flag.BoolVar(&f.Teams, "teams", f.Teams, "play the ends against the side walls in four player mode")
+	flag.StringVar(&f.Host, "host", f.Host, "host a four player game for network players on this address")
+	flag.StringVar(&f.Join, "join", f.Join, "join a four player game hosted at this address")
+	flag.IntVar(&f.JoinSeat, "join-seat", f.JoinSeat, "seat to take when joining a game (0-3)")
+	flag.Float64Var(&f.UIScale, "ui-scale", f.UIScale, "scale of lines and text (0: automatic from display density)")
+	flag.Float64Var(&f.LineWidth, "line-width", f.LineWidth, "width of lines before scaling (0: automatic)")
+	flag.BoolVar(&f.Antialias, "antialias", f.Antialias, "antialias lines")
+	flag.BoolVar(&f.Shadow, "shadow", f.Shadow, "draw the shadow of the ball on the floor")
+	flag.BoolVar(&f.WallMarkers, "wall-markers", f.WallMarkers, "mark the ball position on every wall")
+	flag.BoolVar(&f.CrossingHint, "crossing-hint", f.CrossingHint, "highlight where the ball will cross the paddle plane")
+	flag.IntVar(&f.TrailLength, "trail", f.TrailLength, "length of the ball trail in ticks (0: off)")
+	flag.IntVar(&f.ImpactTime, "impact-time", f.ImpactTime, "ticks the paddle impact ring lasts (0: off)")
+	flag.IntVar(&f.FlashTime, "flash-time", f.FlashTime, "ticks the wall hit flash lasts (0: off)")
+	flag.IntVar(&f.Obstacles, "obstacles", f.Obstacles, "number of roaming obstacles in the arena")
+	flag.Float64Var(&f.ObstacleSize, "obstacle-size", f.ObstacleSize, "obstacle half size")
+	flag.Float64Var(&f.ObstacleSpeed, "obstacle-speed", f.ObstacleSpeed, "obstacle speed")
+	flag.IntVar(&f.ObstaclePath, "obstacle-path", f.ObstaclePath, "obstacle path (0: floor perimeter, 1: bounce around, 2: orbit the center)")
+	flag.BoolVar(&f.Solid, "solid", f.Solid, "draw filled, depth sorted paddles, ball and floor instead of wireframe")
+	flag.IntVar(&f.Stereo, "stereo", f.Stereo, "3D stereo mode (0: off, 1: red/cyan, 2: green/magenta, 3: amber/blue, 4: side by side, 5: top/bottom, 6: interlaced, 7: cross-eye)")
+	flag.Float64Var(&f.IPD, "ipd", f.IPD, "distance between the eyes for stereo")
+	flag.Float64Var(&f.Convergence, "convergence", f.Convergence, "distance from the eyes where stereo images line up")
+	flag.IntVar(&f.Layout, "layout", f.Layout, "two player layout (0: side by side, 1: top/bottom, 2: picture in picture)")
+	flag.Float64Var(&f.TickRate, "tick-rate", f.TickRate, "game updates per second")
+	flag.StringVar(&f.ArenaFile, "arena", f.ArenaFile, "arena file in the assets arenas directory or built in arena ("+strings.Join(arenaNames(), ", ")+")")
+	flag.Float64Var(&f.Arena.X, "arena-width", f.Arena.X, "arena half width")
+	flag.Float64Var(&f.Arena.Y, "arena-height", f.Arena.Y, "arena half height")
+	flag.Float64Var(&f.Arena.Z, "arena-depth", f.Arena.Z, "arena half depth")
+	flag.Float64Var(&f.Paddle.X, "paddle-width", f.Paddle.X, "paddle half width")
+	flag.Float64Var(&f.Paddle.Y, "paddle-height", f.Paddle.Y, "paddle half height")
+	flag.Float64Var(&f.BallSize, "ball-size", f.BallSize, "ball size")
+	flag.Float64Var(&f.BallSpeed, "ball-speed", f.BallSpeed, "initial ball speed")
+	flag.IntVar(&f.Physics, "physics", f.Physics, "ball physics (0: arcade, 1: physical with drag, friction and a speed limit)")
+	flag.Float64Var(&f.Drag, "drag", f.Drag, "part of the ball speed lost to the air every tick in the physical model")
+	flag.Float64Var(&f.Restitution, "restitution", f.Restitution, "part of the speed the ball keeps bouncing in the physical model")
+	flag.Float64Var(&f.Friction, "friction", f.Friction, "friction of surfaces that do not set their own in the physical model")
+	flag.Float64Var(&f.MaxSpeed, "max-speed", f.MaxSpeed, "fastest the ball can go in the physical model")
+	flag.IntVar(&f.MultiBall, "balls", f.MultiBall, "most balls in play at once (1: classic, more: multi-ball)")
+	flag.BoolVar(&f.PowerUps, "powerups", f.PowerUps, "power-ups floating in the arena")
+	flag.Float64Var(&f.PowerUpInterval, "powerup-interval", f.PowerUpInterval, "seconds between power-ups showing up")
+	flag.IntVar(&f.MultiBallRally, "multiball-rally", f.MultiBallRally, "paddle hits in a rally before another ball splits off (0: never)")
+	flag.IntVar(&f.PaddleModel, "paddle-model", f.PaddleModel, "how the paddle sends the ball back (0: flat, 1: curved, 2: momentum)")
+	flag.Float64Var(&f.ComputerSpeed, "computer-speed", f.ComputerSpeed, "computer paddle speed")
+	flag.IntVar(&f.DebrisMin, "debris-min", f.DebrisMin, "minimum debris per hit")
+	flag.IntVar(&f.DebrisMax, "debris-max", f.DebrisMax, "maximum debris per hit")
 
 	flag.Usage = usage
 	flag.Parse()
 
-	game.Gravity = math.Abs(game.Gravity)
-	if game.Gravity < game.MinHandballGravity {
-		game.Gravity = game.MinHandballGravity
+	// Settings given on the command line win over the config file,
+	// so remember them and apply them again after loading it
+	overrides := make(map[string]string)
+	flag.Visit(func(fl *flag.Flag) {
+		overrides[fl.Name] = fl.Value.String()
+	})
+
+	// A missing config file is only an error if it was asked for
+	err := f.Load(game.ConfigFile)
+	if _, explicit := overrides["config"]; err != nil && (explicit || !os.IsNotExist(err)) {
+		ck(err)
+	}
+	for name, value := range overrides {
+		flag.Set(name, value)
 	}
 
-	game.Bound[0] = image.Rect(0, 0, game.Width, game.Height)
-	if game.Mode == TWO_PLAYERS {
-		game.Bound[1] = image.Rect(game.Width*3/2, 0, game.Width*5/2, game.Height)
-		game.Width = game.Bound[1].Max.X
+	ck(f.Validate())
+	if game.DumpConfig {
+		ck(f.Dump())
+		os.Exit(0)
+	}
+	ck(game.openLink())
+	game.applyConfig()
+
+	err = game.LoadKeyMap()
+	if err != nil && !os.IsNotExist(err) {
+		ek(err)
+	}
+	err = game.LoadHighScores()
+	if err != nil && !os.IsNotExist(err) {
+		ek(err)
 	}
+
+	game.Menu.Active = game.Menu.Title
 }
 
 func initSDL() {
 	err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_TIMER)
 	ck(err)
 
+	err = sdl.InitSubSystem(sdl.INIT_GAMECONTROLLER)
+	ek(err)
+
 	err = sdl.InitSubSystem(sdl.INIT_AUDIO)
 	ek(err)
 
@@ -95,38 +195,26 @@ func initSDL() {
 	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "best")
 
 	width, height := game.Width, game.Height
-	wflag := sdl.WINDOW_RESIZABLE
+	wflag := sdl.WINDOW_RESIZABLE | sdl.WINDOW_ALLOW_HIGHDPI
 	if game.Fullscreen {
 		wflag |= sdl.WINDOW_FULLSCREEN_DESKTOP
 	}
 	window, renderer, err := sdl.CreateWindowAndRenderer(width, height, wflag)
 	ck(err)
 
-	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STREAMING, width, height)
-	ck(err)
-
 	err = sdlttf.Init()
 	ck(err)
 
-	font, err := sdlttf.OpenFontMem(ttf.VGA437["default"], 16)
-	ck(err)
-
-	surface, err := sdl.CreateRGBSurfaceWithFormat(sdl.SWSURFACE, width, height, 32, sdl.PIXELFORMAT_ABGR8888)
-	ck(err)
-
 	game.LoadSound("hit")
 	game.LoadSound("score")
 	game.LoadSound("wall")
 
 	window.SetTitle("3D Pong")
-	renderer.SetLogicalSize(width, height)
 
 	game.Window = window
 	game.Renderer = renderer
-	game.Texture = texture
-	game.Surface = surface
-	game.Font = font
-	game.FontHeight = font.Height()
+	game.updateScale()
+	game.openControllers()
 }
 
 func ek(err error) {
@@ -144,61 +232,144 @@ func ck(err error) {
 }
 
 type Game struct {
+	Config     Config
+	ConfigFile string
+	DumpConfig bool
+
 	Window   *sdl.Window
 	Renderer *sdl.Renderer
-	Texture  *sdl.Texture
-	Surface  *sdl.Surface
-	RedBlue  [2][2]color.RGBA
-	Colors   [2][6]color.RGBA
+	Colors   [2][10]color.RGBA
 	Ticker   *time.Ticker
 	Assets   string
 	Sfx      map[string]*sdlmixer.Chunk
 
+	Controllers []*sdl.GameController
+
 	Mode       int
+	Layout     int
 	Bound      [2]image.Rectangle
+	Scale      [2]float64
 	Width      int
 	Height     int
 	Fullscreen bool
 	Sound      bool
 	Pause      bool
+	Menu       Menu
 
 	Font       *sdlttf.Font
+	FontSize   int
 	FontHeight int
+	Atlas      Atlas
+	TextQueue  []TextQuad
+
+	PixelRatio float64
+	UIScale    float64
+	LineWidth  int
+	Display    int
+
+	Antialias bool
+	LineBlend sdl.BlendMode
+	Lines     [2]LineSet
+	Points    []sdl.Point
+	Vertices  []sdl.Vertex
+	Indices   []int32
+
+	Solid [2]bool
+	Prims []Prim
+
+	Shadow       bool
+	WallMarkers  bool
+	CrossingHint bool
+
+	Obstacles     []Obstacle
+	ObstacleCount int
+	ObstacleSize  float64
+	ObstacleSpeed float64
+	ObstaclePath  int
+
+	TrailLength int
+	Effects     []Effect
+	ImpactTime  int
+	FlashTime   int
+
+	IPD          float64
+	Convergence  float64
+	ActiveView   int
+	StereoTarget *sdl.Texture
+	StereoSize   image.Point
+	Rows         []sdl.Rect
+
+	Keys       [2]KeyMap
+	KeyMapFile string
+	Held       [2][NUM_ACTIONS]bool
+	Rebind     Rebind
+	Editor     Editor
 
 	Net       float64
 	NetHeight float64
 
-	Arena       ga.Vec3d
-	PaddleSize  ga.Vec2d
-	GlassOffset float64
+	Arena      ga.Vec3d
+	ArenaDef   ArenaDef
+	Surfaces   []Surface
+	PaddleSize ga.Vec2d
 
 	Aspect             float64
 	Distance           float64
 	Gravity            float64
 	MinHandballGravity float64
+	GravityMode        int
+	GravityDir         ga.Vec3d
+	GravityPeriod      float64
+	GravityTimer       int
+	Drill              int
+	DrillStats         [NUM_DRILLS]DrillStats
+	Shot               Shot
+	ShotIndex          int
+	LaunchInterval     float64
+	LaunchTimer        int
+	Challenge          int
+	Targets            []Target
+	TargetCount        int
+	TargetSize         float64
+	TargetSpeed        float64
+	TargetPoints       int
+	ChallengeTime      float64
+	Combo              int
+	Variant            int
+	Running            bool
+	RunTime            int
+	TimeLeft           int
+	PaddleScale        float64
 	ComputerSpeed      float64
 	ShimmerTime        int
-	Spin               int
+	PaddleModel        int
 
 	Glasses        [2]int
-	Player         [2]ga.Vec2d
-	Shimmering     [2]int
+	Player         [MAX_SEATS]ga.Vec2d
+	LastPlayer     [MAX_SEATS]ga.Vec2d
+	Shimmering     [MAX_SEATS]int
 	OldButton      [2]int
 	OldPos         [2]ga.Vec2d
 	View           [2]int
-	Score          [2]int
+	Score          [MAX_SEATS]int
 	NoClick        [2]bool
-	Queue          [2][]ga.Vec2d
-	QueuePos       [2]int
-	CrapPos        ga.Vec3d
-	CrapVel        ga.Vec3d
+	Queue          [MAX_SEATS][]ga.Vec2d
+	QueuePos       [MAX_SEATS]int
 	BallInPlay     bool
 	BallWaitingFor int
-	HighScore      int
+	HighScores     [NUM_VARIANTS][NUM_CHALLENGES]int
+	HighScoreFile  string
 	FinalScore     int
-	Toggle         bool
 	GotHighScore   bool
 
+	Lives      [MAX_SEATS]int
+	Winner     int
+	Teams      bool
+	DoublesGap float64
+	Viewports  int
+	ViewSeat   [2]int
+	Link       *Link
+
 	AngleDivide float64
 	Angle       [2]ga.Vec2d
 	CosAngle    [2]ga.Vec2d
@@ -206,142 +377,58 @@ type Game struct {
 
 	Quit bool
 
-	BallPos          ga.Vec3d
-	BallVel          ga.Vec3d
-	BallSpeed        float64
+	Balls            []Ball
+	Rally            int
+	MultiBall        int
+	MultiBallRally   int
+	PowerUps         bool
+	PowerUpInterval  float64
+	PowerTimer       int
+	Floating         []PowerUp
+	Powers           []Power
 	BallSize         float64
 	InitialBallSpeed float64
 
-	Debris      []Debris
-	DebrisCount int
+	Physics     int
+	Drag        float64
+	Restitution float64
+	MaxSpeed    float64
+	EndWall     Surface
+
+	Particles   []Particle
 	DebrisTime  int
 	DebrisMin   int
 	DebrisMax   int
 	DebrisSpeed int
 }
 
-type Debris struct {
-	Exist bool
-	Time  int
-	Pos   ga.Vec3d
-	Vel   ga.Vec3d
-}
-
 func NewGame() *Game {
-	const (
-		X_WIDTH  = 100
-		Y_HEIGHT = 100
-		Z_DEPTH  = 150
-
-		PADDLE_WIDTH  = 25
-		PADDLE_HEIGHT = 25
-
-		BALL_SPEED = 2
-
-		GLASS_OFFSET = 10
-		DISTANCE     = Z_DEPTH + 100
-		ASPECT       = 200
-
-		DEBRIS_TIME  = 50
-		DEBRIS_MIN   = 5
-		DEBRIS_MAX   = 10
-		DEBRIS_SPEED = 2
-		NUM_DEBRIS   = 50
-
-		MIN_HANDBALL_GRAVITY = 0.25
-		COMPUTER_SPEED       = 5
-		BALL_SIZE            = 15
-		ANGLE_DIVIDE         = 3
-
-		SHIMMER_TIME = 5
-
-		QUEUE_SIZE = 5
-	)
-
 	c := &Game{
-		Width:  580,
-		Height: 580,
-		Colors: [2][6]color.RGBA{
-			{
-				// red
-				{255, 0, 0, 255},
-				// blue
-				{0, 0, 255, 255},
-				// green
-				{0, 255, 0, 255},
-				// darkred
-				{139, 0, 0, 255},
-				// darkblue
-				{0, 0, 139, 255},
-				// darkgreen
-				{0, 139, 0, 255},
-			},
-			{
-				// red
-				{255, 0, 0, 255},
-				// blue
-				{0, 0, 255, 255},
-				// green
-				{0, 255, 0, 255},
-				// darkred
-				{139, 0, 0, 255},
-				// darkblue
-				{0, 0, 139, 255},
-				// darkgreen
-				{0, 139, 0, 255},
-			},
-		},
-		Arena:              ga.Vec3d{X_WIDTH, Y_HEIGHT, Z_DEPTH},
-		PaddleSize:         ga.Vec2d{PADDLE_WIDTH, PADDLE_HEIGHT},
-		InitialBallSpeed:   BALL_SPEED,
-		ComputerSpeed:      COMPUTER_SPEED,
-		GlassOffset:        GLASS_OFFSET,
-		Aspect:             ASPECT,
-		MinHandballGravity: MIN_HANDBALL_GRAVITY,
-		Debris:             make([]Debris, NUM_DEBRIS),
-		Distance:           DISTANCE,
-		DebrisTime:         DEBRIS_TIME,
-		DebrisMin:          DEBRIS_MIN,
-		DebrisMax:          DEBRIS_MAX,
-		DebrisSpeed:        DEBRIS_SPEED,
-		BallSize:           BALL_SIZE,
-		ShimmerTime:        SHIMMER_TIME,
-		AngleDivide:        ANGLE_DIVIDE,
-		Sound:              true,
-		Sfx:                make(map[string]*sdlmixer.Chunk),
-		Mode:               HANDBALL,
-		RedBlue: [2][2]color.RGBA{
-			{
-				{0, 0, 255, 255},
-				{255, 0, 0, 255},
-			},
-			{
-				{255, 0, 0, 255},
-				{0, 0, 255, 255},
-			},
-		},
-		Ticker: time.NewTicker(80 * time.Millisecond),
+		Config:        DefaultConfig(),
+		ConfigFile:    configPath("config.json"),
+		KeyMapFile:    configPath("keymap.json"),
+		HighScoreFile: configPath("highscores.json"),
+		Sfx:           make(map[string]*sdlmixer.Chunk),
+		Menu:          Menu{Title: true},
 	}
-	for i := range c.Queue {
-		c.Queue[i] = make([]ga.Vec2d, QUEUE_SIZE)
-		c.QueuePos[i] = 0
+	for i := range c.Keys {
+		c.Keys[i] = defaultKeyMap()
 	}
+	c.applyConfig()
 	return c
 }
 
 func (c *Game) Play() {
 	c.reset()
 	for !c.Quit {
-		plns := 1
-		if c.Mode == TWO_PLAYERS {
-			plns = 2
-		}
+		plns := c.Viewports
 		for {
 			ev := sdl.PollEvent()
 			if ev == nil {
 				break
 			}
-			for pln := 0; pln < plns; pln++ {
+			// Later viewports can be drawn over earlier ones, so they get first pick
+			for pln := plns - 1; pln >= 0; pln-- {
 				if c.event(pln, ev) {
 					break
 				}
@@ -354,6 +441,10 @@ func (c *Game) Play() {
 		}
 		c.draw()
 	}
+
+	// A game dropped for another variant or challenge can
+	// still have beaten a high score
+	ek(c.SaveHighScores())
 }
 
 func (c *Game) LoadSound(name string) {
@@ -364,33 +455,42 @@ func (c *Game) LoadSound(name string) {
 }
 
 func (c *Game) reset() {
-	for i := range c.Debris {
-		c.Debris[i] = Debris{}
-	}
-	c.DebrisCount = 0
+	c.Particles = c.Particles[:0]
+	c.placeObstacles()
+	c.placeTargets()
+	c.clearPowers()
+	c.Effects = c.Effects[:0]
 
-	for i := 0; i < 2; i++ {
-		c.OldButton[i] = -1
+	for i := 0; i < MAX_SEATS; i++ {
 		c.Player[i] = ga.Vec2d{}
+		c.LastPlayer[i] = ga.Vec2d{}
 		c.Shimmering[i] = 0
-		c.Glasses[i] = 0
-		c.View[i] = 0
 		c.Score[i] = 0
-		c.Angle[i] = ga.Vec2d{5, 5}
-		c.recalculateTrig(i)
 
-		for j := range c.Queue {
+		for j := range c.Queue[i] {
 			c.Queue[i][j] = ga.Vec2d{}
 		}
 	}
+	c.newGame()
 
-	c.HighScore = 0
+	for i := 0; i < 2; i++ {
+		c.OldButton[i] = -1
+		c.Held[i] = [NUM_ACTIONS]bool{}
+		c.Glasses[i] = c.Config.Stereo
+		c.Solid[i] = c.Config.Solid
+		c.View[i] = 0
+		c.Angle[i] = ga.Vec2d{5, 5}
+		c.recalculateTrig(i)
+	}
+
+	c.resetRun()
 	c.FinalScore = -1
-	c.BallPos = ga.Vec3d{}
-	c.BallVel = ga.Vec3d{}
-	c.BallSpeed = 0
+	c.Balls = c.Balls[:0]
+	c.Rally = 0
 	c.BallInPlay = false
+	c.BallWaitingFor = c.ViewSeat[0]
 	c.Pause = false
+	c.resetDrills()
 }
 
 func (c *Game) recalculateTrig(i int) {
@@ -398,303 +498,278 @@ func (c *Game) recalculateTrig(i int) {
 	c.SinAngle[i].Y, c.CosAngle[i].Y = math.Sincos(c.Angle[i].Y * math.Pi / 180)
 }
 
-func (c *Game) xmouse() int {
-	mx, _, _ := sdl.GetMouseState()
-	ow, _, _ := c.Renderer.OutputSize()
-	v := c.Renderer.Viewport()
-	return int(ga.LinearRemap(float64(mx), float64(v.X), float64(ow)-float64(v.X), 0, float64(c.Width)))
-}
-
 func (c *Game) event(pln int, ev interface{}) bool {
+	if ev, ok := ev.(sdl.WindowEvent); ok {
+		c.windowEvent(ev)
+		return true
+	}
+	if c.Rebind.Active {
+		c.rebindEvent(ev)
+		return true
+	}
+	if c.Menu.Active {
+		c.menuEvent(ev)
+		return true
+	}
+	if c.Editor.Active {
+		c.editorEvent(ev)
+		return true
+	}
+
 	switch ev := ev.(type) {
 	case sdl.QuitEvent:
 		c.Quit = true
-	case sdl.KeyUpEvent:
-		switch ev.Sym {
-		case sdl.K_ESCAPE, sdl.K_q:
+	case sdl.KeyDownEvent:
+		if ev.Repeat != 0 {
+			break
+		}
+		switch {
+		case c.anyKey(ACTION_MENU, ev.Sym):
+			c.openMenu()
+		case c.anyKey(ACTION_QUIT, ev.Sym):
 			c.Quit = true
-		case sdl.K_SPACE, sdl.K_RETURN:
+		case c.anyKey(ACTION_PAUSE, ev.Sym):
 			c.Pause = !c.Pause
+		case c.anyKey(ACTION_EDITOR, ev.Sym):
+			c.openEditor()
+		}
+	case sdl.ControllerButtonDownEvent:
+		if ev.Button == sdl.CONTROLLER_BUTTON_START {
+			c.openMenu()
 		}
 	}
 
-	if c.Quit || c.Pause {
+	if c.Quit || c.Pause || c.Menu.Active || c.Editor.Active {
 		return true
 	}
 
-	mx := c.xmouse()
-	if !(c.Bound[pln].Min.X <= mx && mx <= c.Bound[pln].Max.X) {
+	if !c.mouse().In(c.Bound[pln]) {
 		return false
 	}
 
 	switch ev := ev.(type) {
 	case sdl.KeyDownEvent:
-		switch ev.Sym {
-		case sdl.K_3:
-			c.Glasses[pln] = 1 - c.Glasses[pln]
-		case sdl.K_v:
-			c.View[pln] = (c.View[pln] + 1) % 6
-		case sdl.K_c:
-			c.NoClick[pln] = !c.NoClick[pln]
-		case sdl.K_r:
-			c.reset()
-		}
+		b := Binding{Key: ev.Sym}
+		c.hold(pln, b, true)
+		c.trigger(pln, b)
+	case sdl.KeyUpEvent:
+		c.hold(pln, Binding{Key: ev.Sym}, false)
 	case sdl.MouseButtonDownEvent:
 		// They clicked!  The beginning of a drag!
 		c.OldButton[pln] = int(ev.Button)
 		c.OldPos[pln] = ga.Vec2d{float64(ev.X), float64(ev.Y)}
 
-		// If the ball wasn't in play, this person launched it
-		if !c.BallInPlay && c.BallWaitingFor == pln && ev.Button == sdl.BUTTON_RIGHT {
-			c.putBallInPlay(pln)
-		}
+		b := Binding{Button: int(ev.Button)}
+		c.hold(pln, b, true)
+		c.trigger(pln, b)
 	case sdl.MouseButtonUpEvent:
 		c.OldButton[pln] = -1
+		c.hold(pln, Binding{Button: int(ev.Button)}, false)
 	case sdl.MouseMotionEvent:
-		if c.OldButton[pln] == sdl.BUTTON_LEFT || c.NoClick[pln] {
-			// Add their moves to their queue
-			c.Queue[pln][c.QueuePos[pln]] = ga.Vec2d{float64(ev.Xrel), float64(ev.Yrel)}
-			c.QueuePos[pln] = (c.QueuePos[pln] + 1) % len(c.QueuePos)
-
+		if c.Held[pln][ACTION_MOVE] || c.NoClick[pln] {
 			// Move Paddle
-			c.Player[pln].X += float64(ev.Xrel)
-			c.Player[pln].Y += float64(ev.Yrel)
+			seat := c.ViewSeat[pln]
+			p := &c.Player[seat]
+			p.X += float64(ev.Xrel)
+			p.Y += float64(ev.Yrel)
 
-			c.Player[pln].X = ga.Clamp(c.Player[pln].X, -c.Arena.X+c.PaddleSize.X, c.Arena.X-c.PaddleSize.X)
-			c.Player[pln].Y = ga.Clamp(c.Player[pln].Y, -c.Arena.Y+c.PaddleSize.Y, c.Arena.Y-c.PaddleSize.Y)
+			a := c.seatSize(seat)
+			ps := c.paddleSize(seat)
+			p.X = ga.Clamp(p.X, -a.X+ps.X, a.X-ps.X)
+			p.Y = ga.Clamp(p.Y, -a.Y+ps.Y, a.Y-ps.Y)
 
 			c.OldPos[pln] = ga.Vec2d{float64(ev.X), float64(ev.Y)}
-		} else if c.OldButton[pln] == sdl.BUTTON_MIDDLE {
-			c.Angle[pln] = vec2.Add(c.Angle[pln], ga.Vec2d{float64(ev.Xrel), float64(ev.Yrel)})
-			c.Angle[pln].X = ga.Wrap(c.Angle[pln].X, 0, 360)
-			c.Angle[pln].Y = ga.Wrap(c.Angle[pln].Y, 0, 360)
-
-			c.recalculateTrig(pln)
+		} else if c.Held[pln][ACTION_ORBIT] {
+			c.orbit(pln, ev.Xrel, ev.Yrel)
 			c.OldPos[pln] = ga.Vec2d{float64(ev.X), float64(ev.Y)}
 		}
 	}
 	return true
 }
 
+// orbit turns the FreeView camera as the mouse is dragged.
+func (c *Game) orbit(pln int, xrel, yrel int32) {
+	c.Angle[pln] = vec2.Add(c.Angle[pln], ga.Vec2d{float64(xrel), float64(yrel)})
+	c.Angle[pln].X = ga.Wrap(c.Angle[pln].X, 0, 360)
+	c.Angle[pln].Y = ga.Wrap(c.Angle[pln].Y, 0, 360)
+	c.recalculateTrig(pln)
+}
+
 func (c *Game) update() {
-	if c.Pause {
+	if c.Pause || c.Rebind.Active || c.Menu.Active || c.Editor.Active {
+		return
+	}
+	if c.syncLink() {
+		// The host moves everything but the effects
+		c.moveParticles()
+		c.moveEffects()
 		return
 	}
-	c.moveComputer()
-	c.moveDebris()
-	c.moveCrap()
-	c.moveBall()
+	c.trackPaddles()
+	c.moveSeats()
+	c.rotateGravity()
+	c.moveLauncher()
+	c.moveTargets()
+	c.moveRun()
+	c.moveParticles()
+	c.moveObstacles()
+	c.moveEffects()
+	c.movePowerUps()
+	c.moveBalls()
+	c.collideObstacles()
 }
 
-func (c *Game) moveComputer() {
-	if c.Mode == ONE_PLAYER {
-		// Remove their "ball hit paddle" effect
-		if c.Shimmering[1] != 0 {
-			c.Shimmering[1]--
-		}
+// moveBall moves one ball, it returns false once the ball is out of play.
+func (c *Game) moveBall(b *Ball) bool {
+	// Move it and bounce it off the walls and anything in the arena
+	pace := c.ballPace()
+	c.spinBall(b)
+	c.airBall(b)
+	b.Pos.X += b.Vel.X * pace
+	b.Pos.Y += b.Vel.Y * pace
+	c.collide(&b.Pos, &b.Vel, &b.Spin, c.hitSurface)
 
-		// Move paddle to follow ball
-		if c.BallPos.X > -c.Player[1].X {
-			c.Player[1].X -= (c.ComputerSpeed + float64(rand.Intn(2)))
-		} else if c.BallPos.X < -c.Player[1].X {
-			c.Player[1].X += (c.ComputerSpeed + float64(rand.Intn(2)))
-		}
+	// Add the effect of gravity and the wells
+	b.Vel = vec3.Add(b.Vel, c.pull(b.Pos))
 
-		if c.BallPos.Y < c.Player[1].Y {
-			c.Player[1].Y -= (c.ComputerSpeed + float64(rand.Intn(2)))
-		} else if c.BallPos.Y > c.Player[1].Y {
-			c.Player[1].Y += (c.ComputerSpeed + float64(rand.Intn(2)))
-		}
+	// Move it in/out
+	b.Pos.Z += b.Vel.Z * pace
 
-		// Launch ball if it's our serve
-		if !c.BallInPlay && c.BallWaitingFor == 1 && rand.Intn(10) < 1 {
-			c.putBallInPlay(1)
+	// It's at a goal!
+	for seat := 0; seat < MAX_SEATS; seat++ {
+		if c.front(seat) {
+			c.hitFront(b, seat)
+		} else if !c.hitEnd(b, seat) {
+			return false
 		}
 	}
-}
-
-func (c *Game) moveDebris() {
-	// Move debris
-	for i := range c.Debris {
-		d := &c.Debris[i]
-		if d.Exist {
-			d.Pos = vec3.Add(d.Pos, d.Vel)
-
-			// Add effect of gravity
-			if c.Mode != HANDBALL {
-				d.Vel.Y += c.Gravity / 2
-			} else {
-				d.Vel.Z -= c.Gravity / 2
-			}
 
-			// Count it down, remove if it's old
-			if d.Time--; d.Time <= 0 {
-				d.Exist = false
-			}
+	// Bounce ball of net
+	if c.NetHeight != 0 {
+		if b.Pos.Z >= -c.BallSize && b.Pos.Z <= c.BallSize && b.Pos.Y >= c.NetHeight-c.BallSize {
+			c.hitSurface(ga.Vec3d{b.Pos.X, b.Pos.Y, 0}, ga.Vec3d{0, 0, -math.Copysign(1, b.Vel.Z)})
+			b.Vel.Z = -b.Vel.Z
+			b.Pos.Z += b.Vel.Z
 		}
 	}
+	return true
 }
 
-func (c *Game) moveCrap() {
-	// Move crap
-	c.CrapPos = vec3.Add(c.CrapPos, c.CrapVel)
-	if c.CrapPos.X < -c.Arena.X {
-		c.CrapPos.X = -c.Arena.X
-		c.CrapVel = ga.Vec3d{0, 0, 1}
-	} else if c.CrapPos.X > c.Arena.X {
-		c.CrapPos.X = c.Arena.X
-		c.CrapVel = ga.Vec3d{0, 0, -1}
+// hitEnd handles a ball at the end of the arena a seat guards, it bounces
+// off the paddle or the wall around the goal. It returns false if the ball
+// got past the paddle.
+func (c *Game) hitEnd(b *Ball, seat int) bool {
+	a := c.seatSize(seat)
+	p := c.worldToSeat(seat, b.Pos)
+	if p.Z >= -a.Z+c.BallSize {
+		return true
 	}
 
-	if c.CrapPos.Z < -c.Arena.Z {
-		c.CrapPos.Z = -c.Arena.Z
-		c.CrapVel = ga.Vec3d{-1, 0, 0}
-	} else if c.CrapPos.Z > c.Arena.Z {
-		c.CrapPos.Z = c.Arena.Z
-		c.CrapVel = ga.Vec3d{1, 0, 0}
-	}
-}
+	switch {
+	case c.Mode == PRACTICE && seat == LAUNCHER_SEAT:
+		// Back at the launcher, on to the next shot
+		c.returnBall(b)
+		return false
 
-func (c *Game) moveBall() {
-	// Move ball
-	if !c.BallInPlay {
-		return
-	}
+	case seat >= 2 && !c.guarded(seat):
+		// The side walls bounce the ball in collide
 
-	// Move it left/right
-	c.BallPos.X += c.BallVel.X
-
-	if c.BallPos.X < -c.Arena.X+c.BallSize {
-		c.BallPos.X = -c.Arena.X + c.BallSize
-		c.BallVel.X = -c.BallVel.X
-		c.playSound("wall")
-	} else if c.BallPos.X > c.Arena.X-c.BallSize {
-		c.BallPos.X = c.Arena.X - c.BallSize
-		c.BallVel.X = -c.BallVel.X
-		c.playSound("wall")
-	}
+	case !c.guarded(seat) || seat < 2 && !c.inGoal(seat, b.Pos.X, b.Pos.Y):
+		// Outside of the goal the end wall is just a wall,
+		// the whole of a side wall is a goal
+		c.bounceEnd(b, seat)
+		c.hitSurface(c.seatToWorld(seat, ga.Vec3d{p.X, p.Y, -a.Z}), c.seatToWorld(seat, ga.Vec3d{0, 0, 1}))
+		c.wallRun(b, seat)
 
-	// Move it up/down
-	c.BallPos.Y += c.BallVel.Y
-
-	if c.BallPos.Y < -c.Arena.Y+c.BallSize {
-		c.BallPos.Y = -c.Arena.Y + c.BallSize
-		c.BallVel.Y = -c.BallVel.Y
-		c.playSound("wall")
-	} else if c.BallPos.Y > c.Arena.Y-c.BallSize {
-		c.BallPos.Y = c.Arena.Y - c.BallSize
-		c.BallVel.Y = -c.BallVel.Y
-		c.playSound("wall")
-	}
+	case c.onPaddle(seat, p):
+		// They hit it! Bounce!
+		c.hitPaddle(b, seat)
 
-	// Add the effect of gravity
-	if c.Mode != HANDBALL {
-		c.BallVel.Y += c.Gravity
-	} else {
-		c.BallVel.Z -= c.Gravity
+	case p.Z <= -a.Z && !c.shield(b, seat):
+		c.missBall(b, seat)
+		return false
 	}
+	return true
+}
 
-	// Move it in/out
-	c.BallPos.Z += c.BallVel.Z
-
-	// It's at a goal!
-	if c.BallPos.Z < -c.Arena.Z+c.BallSize {
-		if c.BallPos.X+c.BallSize >= c.Player[0].X-c.PaddleSize.X &&
-			c.BallPos.X-c.BallSize <= c.Player[0].X+c.PaddleSize.X &&
-			c.BallPos.Y+c.BallSize >= c.Player[0].Y-c.PaddleSize.Y &&
-			c.BallPos.Y-c.BallSize <= c.Player[0].Y+c.PaddleSize.Y {
-
-			// They hit it! Bounce!
-			c.addDebris()
-			c.playSound("hit")
-
-			c.BallSpeed += 1
-			c.BallPos.Z = -c.Arena.Z + c.BallSize
-			c.BallVel.Z = float64(rand.Intn(int(c.BallSpeed/2))) + float64(c.BallSpeed)/2
-
-			c.addDebris()
-
-			if c.Spin == 0 {
-				c.BallVel.X = (c.BallPos.X - c.Player[0].X) / c.AngleDivide
-				c.BallVel.Y = (c.BallPos.Y - c.Player[0].Y) / c.AngleDivide
-			} else if c.Spin == 1 {
-				total := c.total(c.Queue[1])
-				c.BallVel.X = total.X
-				c.BallVel.Y = total.Y
-			}
+// bounceEnd bounces a ball off the end wall behind a seat.
+func (c *Game) bounceEnd(b *Ball, seat int) {
+	p := c.worldToSeat(seat, b.Pos)
+	p.Z = -c.seatSize(seat).Z + c.BallSize
+	b.Pos = c.seatToWorld(seat, p)
 
-			c.Shimmering[0] = c.ShimmerTime
+	n := c.seatToWorld(seat, ga.Vec3d{0, 0, 1})
+	if vn := vec3.Dot(b.Vel, n); vn < 0 {
+		c.bounceSurface(&b.Vel, &b.Spin, n, vn, c.EndWall)
+	}
+}
 
-			// A hit in handball mode means score
-			if c.Mode == HANDBALL {
-				c.Score[0]++
-				c.FinalScore = c.Score[0]
+// hitFront bounces a ball off the paddle of a front player in doubles,
+// balls only hit it on their way to the end, it lets them through
+// everywhere else.
+func (c *Game) hitFront(b *Ball, seat int) {
+	if !c.guarded(seat) {
+		return
+	}
+	z := c.paddleZ(seat) + c.BallSize
+	p := c.worldToSeat(seat, b.Pos)
+	v := c.worldToSeat(seat, b.Vel)
+	if v.Z < 0 && p.Z < z && p.Z-v.Z*c.ballPace() >= z && c.onPaddle(seat, p) {
+		c.hitPaddle(b, seat)
+	}
+}
 
-				if c.Score[0] > c.HighScore {
-					c.HighScore = c.Score[0]
-				}
-			}
-		} else if c.BallPos.Z <= -c.Arena.Z {
-			if c.Mode != HANDBALL {
-				// They missed it!  Score to player 2!
-				c.playSound("score")
-				c.BallInPlay = false
-				c.BallWaitingFor = 1
-				c.Score[1]++
-			} else {
-				c.FinalScore = c.Score[0]
-				c.GotHighScore = false
-				if c.FinalScore >= c.HighScore {
-					c.GotHighScore = true
-				}
+// hitPaddle bounces a ball off the paddle of a seat.
+func (c *Game) hitPaddle(b *Ball, seat int) {
+	c.addDebris(seat, b)
+	c.playSound("hit")
+	c.addImpact(seat, b)
+
+	b.Speed += 1
+	p := c.worldToSeat(seat, b.Pos)
+	v := c.worldToSeat(seat, b.Vel)
+	p.Z = c.paddleZ(seat) + c.BallSize
+	v.Z = c.paddleSpeed(b, v.Z)
+
+	smash := c.deflect(b, seat, p, &v)
+	b.Pos = c.seatToWorld(seat, p)
+	b.Vel = c.seatToWorld(seat, v)
+	if smash {
+		c.playSound("score")
+		c.addSparks(b.Pos, c.seatToWorld(seat, ga.Vec3d{0, 0, 1}))
+	}
 
-				c.BallInPlay = false
-				c.BallWaitingFor = 0
-				c.Score[0] = 0
-			}
-		}
-	} else if c.BallPos.Z > c.Arena.Z-c.BallSize {
-		if c.Mode != HANDBALL {
-			if c.BallPos.X+c.BallSize >= -c.Player[1].X-c.PaddleSize.X &&
-				c.BallPos.X-c.BallSize <= -c.Player[1].X+c.PaddleSize.X &&
-				c.BallPos.Y+c.BallSize >= c.Player[1].Y-c.PaddleSize.Y &&
-				c.BallPos.Y-c.BallSize <= c.Player[1].Y+c.PaddleSize.Y {
-
-				// They hit it!  Bounce!
-				c.addDebris()
-				c.playSound("hit")
-
-				c.BallSpeed += 1
-				c.BallPos.Z = c.Arena.Z - c.BallSize
-				c.BallVel.Z = float64(-rand.Intn(int(c.BallSpeed/2))) - c.BallSpeed/2
-
-				c.addDebris()
-
-				if c.Spin == 0 {
-					c.BallVel.X = (c.BallPos.X - c.Player[1].X) / c.AngleDivide
-					c.BallVel.Y = (c.BallPos.Y - c.Player[1].Y) / c.AngleDivide
-				} else if c.Spin == 1 {
-					total := c.total(c.Queue[1])
-					c.BallVel.X = total.X
-					c.BallVel.Y = total.Y
-				}
+	c.addDebris(seat, b)
 
-				c.Shimmering[1] = c.ShimmerTime
-			}
-		} else {
-			c.BallPos.Z = c.Arena.Z - c.BallSize
-			c.BallVel.Z = -c.BallVel.Z
-			c.playSound("wall")
-		}
+	c.Shimmering[seat] = c.ShimmerTime
+	if c.Mode == PRACTICE && b.LastHit != seat {
+		c.DrillStats[c.Drill].Hits++
 	}
+	b.LastHit = seat
+	c.Rally++
 
-	// Bounce ball of net
-	if c.NetHeight != 0 {
-		if c.BallPos.Z >= -c.BallSize && c.BallPos.Z <= c.BallSize && c.BallPos.Y >= c.NetHeight-c.BallSize {
-			c.playSound("wall")
-			c.BallVel.Z = -c.BallVel.Z
-			c.BallPos.Z += c.BallVel.Z
-		}
+	// A hit in handball mode means score
+	c.hitRun(b)
+}
+
+// missBall scores a ball that got past the paddle of a seat.
+func (c *Game) missBall(b *Ball, seat int) {
+	switch c.Mode {
+	case HANDBALL, PRACTICE:
+		// The rally ends with the last ball in endRally
+
+	case FOUR_PLAYERS:
+		c.playSound("score")
+		c.addScoreBurst(b.LastHit, b.Pos)
+		c.loseLife(seat)
+
+	default:
+		// They missed it!  Score to the other player, or team in doubles!
+		other := 1 - seat
+		c.playSound("score")
+		c.addScoreBurst(other, b.Pos)
+		c.BallWaitingFor = seat
+		c.Score[other]++
 	}
 }
 
@@ -720,21 +795,42 @@ func (c *Game) draw() {
 	re := c.Renderer
 	re.SetDrawColor(sdlcolor.Black)
 	re.Clear()
-	plns := 1
-	if c.Mode == TWO_PLAYERS {
-		plns = 2
-	}
+	plns := c.Viewports
 	for pln := 0; pln < plns; pln++ {
+		c.beginViewport(pln)
+		c.beginStereo(pln)
 		c.drawArena(pln)
+		c.drawFloor(pln)
+		c.drawObstacles(pln)
+		c.drawPowerUps(pln)
+		c.drawShields(pln)
 		c.drawFloorMarker(pln)
 		c.drawOpponent(pln)
+		c.drawLauncher(pln)
+		c.drawTargets(pln)
+		c.drawTrail(pln)
 		c.drawBall(pln)
+		c.drawEffects(pln)
 		c.drawYou(pln)
-		c.drawDebris(pln)
+		c.drawParticles(pln)
 		c.drawViewMode(pln)
 		c.drawScores(pln)
+		c.drawPowers(pln)
+		c.drawGravity(pln)
 		c.drawPause(pln)
+		c.drawEditor(pln)
+		c.flushSolid(pln)
+		c.endViewport(pln)
+		c.endStereo()
 	}
+
+	// The menus go over every viewport, without a clip rect
+	for pln := 0; pln < plns; pln++ {
+		c.drawMenu(pln)
+		c.drawRebind(pln)
+	}
+	c.flushLines()
+	c.flushText()
 	re.Present()
 }
 
@@ -743,15 +839,14 @@ func (c *Game) drawPause(pln int) {
 		return
 	}
 	r := &c.Bound[pln]
-	x := r.Dx()/2 - 40
-	y := r.Dy() / 2
-	c.drawText(pln, x, y, sdlcolor.White, "PAUSED")
+	c.drawTextAligned(pln, ALIGN_CENTER, r.Dx()/2, r.Dy()/2, sdlcolor.White, "PAUSED")
 }
 
 func (c *Game) drawArena(pln int) {
-	x := c.Arena.X
-	y := c.Arena.Y
-	z := c.Arena.Z
+	a := c.seatSize(c.ViewSeat[pln])
+	x := a.X
+	y := a.Y
+	z := a.Z
 	col := sdlcolor.White
 
 	// Draw game arena
@@ -829,6 +924,8 @@ func (c *Game) drawArena(pln int) {
 		ga.Vec3d{-x, -y, +z},
 		col,
 	)
+
+	c.drawArenaDef(pln)
 }
 
 func (c *Game) drawFloorMarker(pln int) {
@@ -837,542 +934,326 @@ func (c *Game) drawFloorMarker(pln int) {
 		x := c.Arena.X
 		y := c.Arena.Y
 		col := sdlcolor.White
+		line := func(p, q ga.Vec3d) {
+			c.drawLine(pln, c.toView(pln, p), c.toView(pln, q), col)
+		}
 
-		c.drawLine(
-			pln,
-			ga.Vec3d{-x, +y, 0},
-			ga.Vec3d{+x, +y, 0},
-			col,
-		)
+		line(ga.Vec3d{-x, +y, 0}, ga.Vec3d{+x, +y, 0})
 
 		// Draw net, if any
 		if c.Net != 0 {
 			ny := c.NetHeight
+			line(ga.Vec3d{-x, +ny, 0}, ga.Vec3d{+x, +ny, 0})
+			line(ga.Vec3d{+x, +y, 0}, ga.Vec3d{+x, +ny, 0})
+			line(ga.Vec3d{-x, +ny, 0}, ga.Vec3d{-x, +y, 0})
+		}
+	}
+}
+
+func (c *Game) drawOpponent(pln int) {
+	// Draw everyone else
+	for seat := 0; seat < MAX_SEATS; seat++ {
+		if seat != c.ViewSeat[pln] && c.guarded(seat) {
+			c.drawPaddle(pln, seat, c.seatColor(pln, seat, true))
+		}
+	}
+}
+
+func (c *Game) drawBall(pln int) {
+	if c.BallInPlay {
+		for i := range c.Balls {
+			// Draw ball
+			b := &c.Balls[i]
+			p := c.toView(pln, b.Pos)
+			x, y, z := p.X, p.Y, p.Z
+			s := c.BallSize
+			col := c.Colors[pln][2]
+
+			// Only the markers show where an invisible ball is
+			if c.ballHidden(pln) {
+				c.drawBallCues(pln, b)
+				continue
+			}
+
+			c.drawSolidBall(pln, x, y, z, s, col)
 			c.drawLine(
 				pln,
-				ga.Vec3d{-x, +ny, 0},
-				ga.Vec3d{+x, +ny, 0},
+				ga.Vec3d{x - s, y, z - s},
+				ga.Vec3d{x + s, y, z - s},
 				col,
 			)
 			c.drawLine(
 				pln,
-				ga.Vec3d{+x, +y, 0},
-				ga.Vec3d{+x, +ny, 0},
+				ga.Vec3d{x + s, y, z - s},
+				ga.Vec3d{x + s, y, z + s},
 				col,
 			)
 			c.drawLine(
 				pln,
-				ga.Vec3d{-x, +ny, 0},
-				ga.Vec3d{-x, +y, 0},
+				ga.Vec3d{x + s, y, z + s},
+				ga.Vec3d{x - s, y, z + s},
+				col,
+			)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x - s, y, z + s},
+				ga.Vec3d{x - s, y, z - s},
 				col,
 			)
-		}
-	}
-}
-
-func (c *Game) drawOpponent(pln int) {
-	// Draw opponent
-	if c.Mode == HANDBALL {
-		return
-	}
-	x := c.Player[1-pln].X
-	y := c.Player[1-pln].Y
-	z := c.Arena.Z
-
-	pw := c.PaddleSize.X
-	ph := c.PaddleSize.Y
-
-	col := c.Colors[pln][1-pln+3]
 
-	c.drawLine(
-		pln,
-		ga.Vec3d{-x + pw, +y - ph, +z},
-		ga.Vec3d{-x - pw, +y - ph, +z},
-		col,
-	)
-	c.drawLine(
-		pln,
-		ga.Vec3d{-x - pw, +y - ph, +z},
-		ga.Vec3d{-x - pw, +y + ph, +z},
-		col,
-	)
-	c.drawLine(
-		pln,
-		ga.Vec3d{-x - pw, +y + ph, +z},
-		ga.Vec3d{-x + pw, +y + ph, +z},
-		col,
-	)
-	c.drawLine(
-		pln,
-		ga.Vec3d{-x + pw, +y + ph, +z},
-		ga.Vec3d{-x + pw, +y - ph, +z},
-		col,
-	)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x - s, y, z - s},
+				ga.Vec3d{x, y - s, z},
+				col,
+			)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x + s, y, z - s},
+				ga.Vec3d{x, y - s, z},
+				col,
+			)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x + s, y, z + s},
+				ga.Vec3d{x, y - s, z},
+				col,
+			)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x - s, y, z + s},
+				ga.Vec3d{x, y - s, z},
+				col,
+			)
 
-	// Draw "paddle hit the ball" effect
-	if c.Shimmering[1-pln] != 0 {
-		col := c.Colors[pln][1-pln+3]
-		c.drawLine(
-			pln,
-			ga.Vec3d{-x - pw, y - ph, z},
-			ga.Vec3d{-x + pw, y + ph, z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{-x + pw, y - ph, z},
-			ga.Vec3d{-x - pw, y + ph, z},
-			col,
-		)
-	}
-}
+			c.drawLine(
+				pln,
+				ga.Vec3d{x - s, y, z - s},
+				ga.Vec3d{x, y + s, z},
+				col,
+			)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x + s, y, z - s},
+				ga.Vec3d{x, y + s, z},
+				col,
+			)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x + s, y, z + s},
+				ga.Vec3d{x, y + s, z},
+				col,
+			)
+			c.drawLine(
+				pln,
+				ga.Vec3d{x - s, y, z + s},
+				ga.Vec3d{x, y + s, z},
+				col,
+			)
 
-func (c *Game) drawBall(pln int) {
-	if c.BallInPlay {
-		// Draw ball
-		var x, z float64
-		if pln == 0 {
-			x = c.BallPos.X
-			z = c.BallPos.Z
-		} else {
-			x = -c.BallPos.X
-			z = -c.BallPos.Z
+			// Draw ball markers
+			c.drawBallCues(pln, b)
 		}
-		y := c.BallPos.Y
-		s := c.BallSize
-		col := c.Colors[pln][2]
-
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - s, y, z - s},
-			ga.Vec3d{x + s, y, z - s},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x + s, y, z - s},
-			ga.Vec3d{x + s, y, z + s},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x + s, y, z + s},
-			ga.Vec3d{x - s, y, z + s},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - s, y, z + s},
-			ga.Vec3d{x - s, y, z - s},
-			col,
-		)
-
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - s, y, z - s},
-			ga.Vec3d{x, y - s, z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x + s, y, z - s},
-			ga.Vec3d{x, y - s, z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x + s, y, z + s},
-			ga.Vec3d{x, y - s, z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - s, y, z + s},
-			ga.Vec3d{x, y - s, z},
-			col,
-		)
-
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - s, y, z - s},
-			ga.Vec3d{x, y + s, z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x + s, y, z - s},
-			ga.Vec3d{x, y + s, z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x + s, y, z + s},
-			ga.Vec3d{x, y + s, z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - s, y, z + s},
-			ga.Vec3d{x, y + s, z},
-			col,
-		)
-
-		// Draw ball markers
-		col = c.Colors[pln][5]
-		y = -c.Arena.Y
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - s, -y, z},
-			ga.Vec3d{x + s, -y, z},
-			col,
-		)
-
-		x = c.Arena.X
-		y = c.BallPos.Y
-		c.drawLine(
-			pln,
-			ga.Vec3d{-x, y - s, z},
-			ga.Vec3d{-x, y + s, z},
-			col,
-		)
 	} else {
 		// Ball isn't in play, waiting for someone...
 		var text string
-		if c.BallWaitingFor == pln {
+		if c.Mode == PRACTICE {
+			text = "Get ready!"
+		} else if c.BallWaitingFor == c.ViewSeat[pln] {
 			text = fmt.Sprintf("Your serve!")
 		} else {
-			text = fmt.Sprintf("Player %d's serve", (1-pln)+1)
+			text = fmt.Sprintf("Player %d's serve", c.BallWaitingFor+1)
 		}
 
-		c.drawText(pln, 50, c.Height/2, sdlcolor.White, text)
+		x := c.Bound[pln].Dx() / 2
+		y := c.Bound[pln].Dy() / 2
+		c.drawTextAligned(pln, ALIGN_CENTER, x, y, sdlcolor.White, text)
 
 		// Show final score (handball)
 		if c.Mode == HANDBALL {
 			fh := c.FontHeight
 			// Only show it if they've actually played a round yet
-			if c.FinalScore != -1 {
+			if c.FinalScore != -1 && !c.Running {
 				text = fmt.Sprintf("Final score: %d", c.FinalScore)
-				c.drawText(pln, 50, int(c.Height)/2+fh*2, sdlcolor.White, text)
+				c.drawTextAligned(pln, ALIGN_CENTER, x, y+fh*2, sdlcolor.White, text)
 			}
 
 			// Show "got high score" if they got it (handball)
 			if c.GotHighScore {
-				c.drawText(pln, 50, int(c.Height)/2+fh*3, sdlcolor.White, "You beat the high score!")
+				c.drawTextAligned(pln, ALIGN_CENTER, x, y+fh*3, sdlcolor.White, "You beat the high score!")
 			}
 		}
 	}
 }
 
 func (c *Game) drawYou(pln int) {
-	x := c.Player[pln].X
-	y := c.Player[pln].Y
-	z := c.Arena.Z
-	pw := c.PaddleSize.X
-	ph := c.PaddleSize.Y
-	col := c.Colors[pln][pln]
-
-	c.drawLine(
-		pln,
-		ga.Vec3d{x - pw, y - ph, -z},
-		ga.Vec3d{x + pw, y - ph, -z},
-		col,
-	)
-	c.drawLine(
-		pln,
-		ga.Vec3d{x + pw, y - ph, -z},
-		ga.Vec3d{x + pw, y + ph, -z},
-		col,
-	)
-	c.drawLine(
-		pln,
-		ga.Vec3d{x + pw, y + ph, -z},
-		ga.Vec3d{x - pw, y + ph, -z},
-		col,
-	)
-	c.drawLine(
-		pln,
-		ga.Vec3d{x - pw, y + ph, -z},
-		ga.Vec3d{x - pw, y - ph, -z},
-		col,
-	)
-
-	// Draw "paddle hit the ball" effect
-	if c.Shimmering[pln] != 0 {
-		c.Shimmering[pln]--
-
-		c.drawLine(
-			pln,
-			ga.Vec3d{x - pw, y - ph, -z},
-			ga.Vec3d{x + pw, y + ph, -z},
-			col,
-		)
-		c.drawLine(
-			pln,
-			ga.Vec3d{x + pw, y - ph, -z},
-			ga.Vec3d{x - pw, y + ph, -z},
-			col,
-		)
+	seat := c.ViewSeat[pln]
+	if !c.guarded(seat) {
+		return
 	}
-}
+	c.drawPaddle(pln, seat, c.seatColor(pln, seat, false))
 
-func (c *Game) drawDebris(pln int) {
-	for i := range c.Debris {
-		d := &c.Debris[i]
-		if d.Exist {
-			col := c.Colors[pln][rand.Intn(3)]
-			c.drawLine(pln, d.Pos, vec3.Add(d.Pos, d.Vel), col)
-		}
+	// Remove your "ball hit paddle" effect
+	if c.Shimmering[seat] != 0 && c.seatType(seat) == SEAT_HUMAN {
+		c.Shimmering[seat]--
 	}
 }
 
 func (c *Game) drawViewMode(pln int) {
 	// Draw view mode
-	x := 10
+	x := c.px(TEXT_MARGIN)
 	fh := c.FontHeight
+	y := c.Bound[pln].Dy() - c.px(TEXT_MARGIN) - fh
 	viewNames := [...]string{
 		"Normal", "Bleachers", "Above", "FreeView",
 		"Follow the Ball", "From The Paddle",
 	}
-	c.drawText(pln, x, c.Height-fh, c.Colors[pln][2], viewNames[c.View[pln]])
 	if c.View[pln] == 3 {
-		c.drawText(pln, x, c.Height, c.Colors[pln][5], "Middle-Click and drag to change view")
+		c.drawText(pln, x, y, c.Colors[pln][5], "%s and drag to change view", c.Keys[pln].Describe(ACTION_ORBIT))
+		y -= fh
+	}
+	if c.Glasses[pln] != STEREO_OFF {
+		c.drawText(pln, x, y, c.Colors[pln][5], "3D: %s", stereoModes[c.Glasses[pln]].Name)
+		y -= fh
 	}
+	c.drawText(pln, x, y, c.Colors[pln][2], viewNames[c.View[pln]])
 }
 
 func (c *Game) drawScores(pln int) {
 	fh := c.FontHeight
-	x := 10
+	x := c.px(TEXT_MARGIN)
 	// Draw scores
-	if c.Mode != HANDBALL {
-		// Player 1 and 2 scores
+	if c.drawRun(pln) {
+		return
+	}
+	switch c.Mode {
+	case FOUR_PLAYERS:
+		c.drawLives(pln)
+	case PRACTICE:
+		c.drawDrills(pln)
+	default:
+		// Player 1 and 2 scores, your own first
+		name := "Player"
+		if c.Mode == DOUBLES {
+			name = "Team"
+		}
 		for i := 0; i < 2; i++ {
-			t := [2]int{1, 2}
-			if pln == 1 {
-				t = [2]int{2, 1}
-			}
-			c.drawText(pln, x, fh*(i+1), c.Colors[pln][i], "Player %d: %d", t[i], c.Score[t[i]-1])
+			seat := (c.team(c.ViewSeat[pln]) + i) % 2
+			c.drawText(pln, x, fh*(i+1), c.Colors[pln][i], "%s %d: %d", name, seat+1, c.Score[seat])
 		}
-	} else {
-		// Score and high score for handball
-		c.drawText(pln, x, fh, c.Colors[0][0], "Score: %d", c.Score[0])
-		c.drawText(pln, x, fh*2, c.Colors[0][0], "High:  %d", c.HighScore)
 	}
 }
 
-func (c *Game) drawText(pln, x, y int, col color.RGBA, format string, args ...interface{}) {
-	x += c.Bound[pln].Min.X
-	text := fmt.Sprintf(format, args...)
-	texture := c.Texture
-	surface := c.Surface
-	font := c.Font
-
-	r, err := font.RenderUTF8BlendedEx(surface, text, col)
-	ck(err)
+func (c *Game) drawLine(pln int, p1, p2 ga.Vec3d, col color.RGBA) {
+	if c.Solid[pln] {
+		c.drawSolidLine(pln, p1, p2, col)
+		return
+	}
 
-	p, err := texture.Lock(nil)
-	ck(err)
+	// Alter perceived x/y/z depending on their view
+	pp1 := c.transform(pln, p1)
+	pp2 := c.transform(pln, p2)
 
-	err = surface.Lock()
-	ck(err)
-	s := surface.Pixels()
-	for i := 0; i < len(p); i += 4 {
-		p[i] = s[i+2]
-		p[i+1] = s[i+1]
-		p[i+2] = s[i]
-		p[i+3] = s[i+3]
+	// Inside of the distance clip plane
+	if pp1.Z <= -c.Distance || pp2.Z <= -c.Distance {
+		return
 	}
 
-	surface.Unlock()
-	texture.Unlock()
-
-	texture.SetBlendMode(sdl.BLENDMODE_BLEND)
-	c.Renderer.Copy(texture, &sdl.Rect{0, 0, r.W, r.H}, &sdl.Rect{int32(x), int32(y), r.W, r.H})
+	// Queue the line to be drawn into the window, once for each eye
+	for eye := 0; eye < c.eyes(pln); eye++ {
+		s1 := c.project(pln, eye, pp1)
+		s2 := c.project(pln, eye, pp2)
+		c.queueEyeLine(pln, eye, col, s1, s2)
+	}
 }
 
-func (c *Game) drawLine(pln int, p1, p2 ga.Vec3d, col color.RGBA) {
-	re := c.Renderer
-
-	var (
-		xoff     float64
-		pp1, pp2 ga.Vec3d
-		s1, s2   ga.Vec2d
-	)
-	for i := 0; i < c.Glasses[pln]+1; i++ {
-		if c.Glasses[pln] == 0 {
-			xoff = 0
-		} else if c.Glasses[pln] == 1 {
-			xoff = c.GlassOffset
-
-			if i == 0 {
-				xoff = -xoff
-			}
-			if !c.Toggle {
-				xoff = -xoff
-			}
-		}
-
-		// Alter perceived x/y/z depending on their view
-		switch c.View[pln] {
-		case 0: // Normal (behind your paddle)
-			pp1 = p1
-			pp2 = p2
-
-		case 1: // From the side
-			pp1 = ga.Vec3d{p1.Z, p1.Y, p1.X}
-			pp2 = ga.Vec3d{p2.Z, p2.Y, p2.X}
-
-		case 2: // From above
-			pp1 = ga.Vec3d{p1.X, p1.Z, p1.Y}
-			pp2 = ga.Vec3d{p2.X, p2.Z, p2.Y}
-
-		case 3: // Free view
-			xx1 := p1.X*c.CosAngle[pln].X - p1.Z*c.SinAngle[pln].X
-			zz1 := p1.X*c.SinAngle[pln].X + p1.Z*c.CosAngle[pln].X
-
-			yy1 := p1.Y*c.CosAngle[pln].Y - zz1*c.SinAngle[pln].Y
-			zz1 = p1.Y*c.SinAngle[pln].Y + zz1*c.CosAngle[pln].Y
-
-			xx2 := p2.X*c.CosAngle[pln].X - p2.Z*c.SinAngle[pln].X
-			zz2 := p2.X*c.SinAngle[pln].X + p2.Z*c.CosAngle[pln].X
+// transform moves a point in the arena into the camera space of the view.
+func (c *Game) transform(pln int, p ga.Vec3d) ga.Vec3d {
+	switch c.View[pln] {
+	case 0: // Normal (behind your paddle)
+		return p
 
-			yy2 := p2.Y*c.CosAngle[pln].Y - zz2*c.SinAngle[pln].Y
-			zz2 = p2.Y*c.SinAngle[pln].Y + zz2*c.CosAngle[pln].Y
+	case 1: // From the side
+		return ga.Vec3d{p.Z, p.Y, p.X}
 
-			pp1 = ga.Vec3d{xx1, yy1, zz1}
-			pp2 = ga.Vec3d{xx2, yy2, zz2}
+	case 2: // From above
+		return ga.Vec3d{p.X, p.Z, p.Y}
 
-		case 4: // Watch the ball
-			ball := &c.BallPos
-			anglex := (ball.Z - c.Arena.Z) / 10
-			anglex = ga.Clamp(anglex, -90, 90)
+	case 3: // Free view
+		xx := p.X*c.CosAngle[pln].X - p.Z*c.SinAngle[pln].X
+		zz := p.X*c.SinAngle[pln].X + p.Z*c.CosAngle[pln].X
 
-			anglex = (anglex / 180) * math.Pi
-			sinx, cosx := math.Sincos(anglex)
+		yy := p.Y*c.CosAngle[pln].Y - zz*c.SinAngle[pln].Y
+		zz = p.Y*c.SinAngle[pln].Y + zz*c.CosAngle[pln].Y
 
-			xx1 := p1.X - (ball.X / 5)
-			yy1 := (p1.Z-ball.Z)*sinx + (p1.Y-ball.Y)*cosx
-			zz1 := (p1.Z-ball.Z)*cosx - (p1.Y-ball.Y)*sinx + 30
+		return ga.Vec3d{xx, yy, zz}
 
-			xx2 := p2.X - (ball.X / 5)
-			yy2 := (p2.Z-ball.Z)*sinx + (p2.Y-ball.Y)*cosx
-			zz2 := (p2.Z-ball.Z)*cosx - (p2.Y-ball.Y)*sinx + 30
-
-			pp1 = ga.Vec3d{xx1, yy1, zz1}
-			pp2 = ga.Vec3d{xx2, yy2, zz2}
-
-		case 5: // From your paddle
-			pp1 = ga.Vec3d{
-				p1.X - c.Player[pln].X,
-				p1.Y - c.Player[pln].Y,
-				p1.Z,
-			}
-
-			pp2 = ga.Vec3d{
-				p2.X - c.Player[pln].X,
-				p2.Y - c.Player[pln].Y,
-				p2.Z,
-			}
+	case 4: // Watch the ball
+		var ball ga.Vec3d
+		seat := c.ViewSeat[pln]
+		if b := c.threat(seat); b != nil {
+			ball = c.toView(pln, b.Pos)
 		}
+		anglex := (ball.Z - c.seatSize(seat).Z) / 10
+		anglex = ga.Clamp(anglex, -90, 90)
 
-		// Inside of the distance clip plane
-		if pp1.Z > -c.Distance && pp2.Z > -c.Distance {
-			// Convert (x,y,z) into (x,y) with a 3D look;
-			s1 = ga.Vec2d{
-				(pp1.X + xoff) / ((pp1.Z + c.Distance) / c.Aspect),
-				pp1.Y / ((pp1.Z + c.Distance) / c.Aspect),
-			}
+		anglex = (anglex / 180) * math.Pi
+		sinx, cosx := math.Sincos(anglex)
 
-			s2 = ga.Vec2d{
-				(pp2.X + xoff) / ((pp2.Z + c.Distance) / c.Aspect),
-				pp2.Y / ((pp2.Z + c.Distance) / c.Aspect),
-			}
+		xx := p.X - (ball.X / 5)
+		yy := (p.Z-ball.Z)*sinx + (p.Y-ball.Y)*cosx
+		zz := (p.Z-ball.Z)*cosx - (p.Y-ball.Y)*sinx + 30
 
-			// Transpose (0, 0) origin to center of window
-			r := &c.Bound[pln]
-			width := r.Dx()
-			height := r.Dy()
-
-			s1.X += float64(r.Min.X)
-			s1.X += float64(width) / 2
-			s1.Y += float64(height) / 2
-
-			s2.X += float64(r.Min.X)
-			s2.X += float64(width) / 2
-			s2.Y += float64(height) / 2
-
-			// Draw the line into the window
-			if c.Glasses[pln] == 0 {
-				re.SetDrawColor(col)
-				re.DrawLine(int(s1.X), int(s1.Y), int(s2.X), int(s2.Y))
-			} else {
-				if (i == 0 && c.Toggle) || (i == 1 && !c.Toggle) {
-					re.SetDrawColor(c.RedBlue[pln][0])
-					re.DrawLine(int(s1.X), int(s1.Y), int(s2.X), int(s2.Y))
-				} else {
-					re.SetDrawColor(c.RedBlue[pln][1])
-					re.DrawLine(int(s1.X), int(s1.Y+1), int(s2.X), int(s2.Y+1))
-				}
-			}
+		return ga.Vec3d{xx, yy, zz}
+
+	case 5: // From your paddle
+		paddle := c.Player[c.ViewSeat[pln]]
+		return ga.Vec3d{
+			p.X - paddle.X,
+			p.Y - paddle.Y,
+			p.Z,
 		}
 	}
+	return p
 }
 
-func (c *Game) addDebris() {
-	n := rand.Intn(c.DebrisMax-c.DebrisMin) + c.DebrisMin
-	for i := 0; i < n; i++ {
-		d := &c.Debris[c.DebrisCount]
-
-		// Make it exist
-		d.Exist = true
-		d.Time = rand.Intn(c.DebrisTime)
-
-		// Give it a position
-		p := &d.Pos
-		v := &c.BallPos
-		p.X = v.X + float64(rand.Intn(10)) - 5
-		p.Y = v.Y + float64(rand.Intn(10)) - 5
-		p.Z = v.Z + float64(rand.Intn(10)) - 5
-
-		// Give it a speed/direction
-		p = &d.Vel
-		v = &c.BallVel
-		p.X = (float64(rand.Intn(c.DebrisSpeed*2)) - float64(c.DebrisSpeed) + v.X/2)
-		p.Y = 0
-		p.Z = 0
-
-		// Increment the debris counter
-		c.DebrisCount = (c.DebrisCount + 1) % len(c.Debris)
+func (c *Game) putBallInPlay(seat int) {
+	// A new game starts with the first serve after someone won
+	if c.Winner >= 0 {
+		c.newGame()
 	}
-}
 
-func (c *Game) putBallInPlay(pln int) {
 	// Remember that the ball is now in play
 	c.BallInPlay = true
+	c.Rally = 0
+	c.startRun()
 
-	// Pick a random starting position
-	c.BallPos = ga.Vec3d{
-		c.Player[pln].X,
-		c.Player[pln].Y,
-		c.Arena.Z / 2,
+	// The launcher serves in practice mode
+	if c.Mode == PRACTICE {
+		c.Balls = append(c.Balls[:0], c.launch())
+		return
 	}
 
-	if pln == 0 {
-		c.BallPos.X = -c.BallPos.X
-		c.BallPos.Z = -c.BallPos.Z
-	}
+	// Pick a random starting position
+	a := c.seatSize(seat)
+	b := Ball{Pos: c.seatToWorld(seat, ga.Vec3d{
+		-c.Player[seat].X,
+		c.Player[seat].Y,
+		-a.Z / 2,
+	})}
 
 	// Give it a random speed/direction
-	c.BallSpeed = c.InitialBallSpeed
-	c.BallVel.X = float64(rand.Intn(int(c.BallSpeed*2))) - c.BallSpeed
-	c.BallVel.Y = float64(rand.Intn(int(c.BallSpeed*2))) - c.BallSpeed
+	b.Speed = c.InitialBallSpeed
+	var v ga.Vec3d
+	v.X = float64(rand.Intn(int(b.Speed*2))) - b.Speed
+	v.Y = float64(rand.Intn(int(b.Speed*2))) - b.Speed
 	for {
-		c.BallVel.Z = float64(rand.Intn(int(c.InitialBallSpeed*3))) / 2
-		if c.BallVel.Z != 0 {
+		v.Z = float64(rand.Intn(int(c.InitialBallSpeed*3))) / 2
+		if v.Z != 0 {
 			break
 		}
 	}
-
-	if pln == 1 {
-		c.BallVel.Z = -c.BallVel.Z
-	}
+	b.Vel = c.seatToWorld(seat, v)
+	b.LastHit = seat
+	c.Balls = append(c.Balls[:0], b)
 }
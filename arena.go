@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+const (
+	GOAL_FULL = iota
+	GOAL_RECT
+	GOAL_ELLIPSE
+	NUM_GOALS
+)
+
+const (
+	// Bounciness of a bumper that does not give one
+	BUMPER_BOUNCE = 1.5
+)
+
+var (
+	slabColor   = color.RGBA{128, 128, 255, 255}
+	bumperColor = color.RGBA{255, 0, 255, 255}
+	goalColor   = color.RGBA{255, 255, 0, 255}
+)
+
+// An ArenaDef describes the geometry of an arena, it is read from a JSON
+// file or comes from one of the built in arenas. Positions are in arena
+// coordinates, the center of the arena is the origin, player 1 is at -Z
+// and player 2 is at +Z.
+type ArenaDef struct {
+	Name string
+
+	// Half size of the arena, zero uses the size in the config
+	Size ga.Vec3d
+
+	// How much speed the ball keeps bouncing off each surface, and
+	// how much friction slows it along them in the physical model,
+	// no friction at all uses the friction in the config
+	Bounce   Bounciness
+	Friction Friction
+
+	Slabs   []Slab
+	Bumpers []Bumper
+	Wells   []Well
+
+	// The part of each end wall where a miss counts, the ball
+	// bounces off the rest of the end wall
+	Goals [2]Goal
+}
+
+type Bounciness struct {
+	Left    float64
+	Right   float64
+	Ceiling float64
+	Floor   float64
+	End     float64
+}
+
+type Friction Bounciness
+
+// A Slab is a solid box inside of the arena, a Bounce of 0 is 1
+// and a Friction of 0 is the friction in the config.
+type Slab struct {
+	Min      ga.Vec3d
+	Max      ga.Vec3d
+	Bounce   float64
+	Friction float64
+}
+
+// A Bumper is a sphere that kicks the ball away harder than it came in,
+// a Bounce of 0 is BUMPER_BOUNCE and a Friction of 0 is the friction
+// in the config.
+type Bumper struct {
+	Pos      ga.Vec3d
+	Radius   float64
+	Bounce   float64
+	Friction float64
+}
+
+// A Goal is a shape on an end wall, Center and Size are in X and Y
+// and Size is the half size of the shape.
+type Goal struct {
+	Shape  int
+	Center ga.Vec2d
+	Size   ga.Vec2d
+}
+
+// The built in arenas, sized to fit the arena in the config.
+var builtinArenas = []struct {
+	Name string
+	Make func(a ga.Vec3d) ArenaDef
+}{
+	{"classic", func(a ga.Vec3d) ArenaDef {
+		return ArenaDef{}
+	}},
+	{"pillars", func(a ga.Vec3d) ArenaDef {
+		w := a.X / 10
+		return ArenaDef{
+			Slabs: []Slab{
+				{Min: ga.Vec3d{-a.X/2 - w, -a.Y, -w}, Max: ga.Vec3d{-a.X/2 + w, a.Y, w}},
+				{Min: ga.Vec3d{a.X/2 - w, -a.Y, -w}, Max: ga.Vec3d{a.X/2 + w, a.Y, w}},
+			},
+		}
+	}},
+	{"bumpers", func(a ga.Vec3d) ArenaDef {
+		r := math.Min(a.X, a.Y) / 8
+		var b []Bumper
+		for _, x := range [...]float64{-a.X / 2, a.X / 2} {
+			for _, z := range [...]float64{-a.Z / 3, a.Z / 3} {
+				b = append(b, Bumper{Pos: ga.Vec3d{x, 0, z}, Radius: r})
+			}
+		}
+		return ArenaDef{Bumpers: b}
+	}},
+	{"goals", func(a ga.Vec3d) ArenaDef {
+		g := Goal{Shape: GOAL_RECT, Size: ga.Vec2d{a.X / 2, a.Y / 2}}
+		return ArenaDef{Goals: [2]Goal{g, g}}
+	}},
+	{"tunnel", func(a ga.Vec3d) ArenaDef {
+		d := a.Z / 30
+		g := Goal{Shape: GOAL_ELLIPSE, Size: ga.Vec2d{a.X * 0.6, a.Y * 0.6}}
+		return ArenaDef{
+			Slabs: []Slab{
+				{Min: ga.Vec3d{-a.X, -a.Y, -d}, Max: ga.Vec3d{a.X, -a.Y / 3, d}},
+				{Min: ga.Vec3d{-a.X, a.Y / 3, -d}, Max: ga.Vec3d{a.X, a.Y, d}},
+			},
+			Goals: [2]Goal{g, g},
+		}
+	}},
+	{"sticky", func(a ga.Vec3d) ArenaDef {
+		return ArenaDef{
+			Bounce: Bounciness{Left: 0.7, Right: 0.7, Ceiling: 0.7, Floor: 0.9, End: 0.7},
+		}
+	}},
+}
+
+func arenaNames() []string {
+	var names []string
+	for _, b := range builtinArenas {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+func defaultArenaDef() ArenaDef {
+	return ArenaDef{
+		Bounce: Bounciness{1, 1, 1, 1, 1},
+	}
+}
+
+// arenaPath is where the arena file name is, relative
+// to the arenas in the assets directory.
+func (c *Game) arenaPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.Assets, "arenas", name)
+}
+
+// loadArena returns the built in arena called name, or reads it from
+// the file name if there is no built in arena by that name.
+func (c *Game) loadArena(name string, size ga.Vec3d) (ArenaDef, error) {
+	for _, b := range builtinArenas {
+		if strings.EqualFold(b.Name, name) {
+			d := defaultArenaDef()
+			m := b.Make(size)
+			if m.Bounce != (Bounciness{}) {
+				d.Bounce = m.Bounce
+			}
+			d.Friction = m.Friction
+			d.Name = b.Name
+			d.Slabs = m.Slabs
+			d.Bumpers = m.Bumpers
+			d.Wells = m.Wells
+			d.Goals = m.Goals
+			return d, nil
+		}
+	}
+
+	d := defaultArenaDef()
+	fd, err := os.Open(c.arenaPath(name))
+	if err != nil {
+		return d, err
+	}
+	defer fd.Close()
+
+	dec := json.NewDecoder(fd)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&d)
+	if err != nil {
+		return d, fmt.Errorf("%s: %v", name, err)
+	}
+	if d.Name == "" {
+		d.Name = name
+	}
+	return d, nil
+}
+
+// Validate checks d, the size of the arena has to fit the
+// rest of the config f just as much as the one in f does.
+func (d *ArenaDef) Validate(f *Config) error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	size := f.Arena
+	if d.Size != (ga.Vec3d{}) {
+		size = d.Size
+		errs = append(errs, f.fitArena("Size", d.Size)...)
+	}
+
+	b := &d.Bounce
+	for _, v := range [...]float64{b.Left, b.Right, b.Ceiling, b.Floor, b.End} {
+		check(v >= 0, "Bounce (%v) must not be negative", *b)
+	}
+	fr := &d.Friction
+	for _, v := range [...]float64{fr.Left, fr.Right, fr.Ceiling, fr.Floor, fr.End} {
+		check(v >= 0, "Friction (%v) must not be negative", *fr)
+	}
+	for i, s := range d.Slabs {
+		check(s.Min.X < s.Max.X && s.Min.Y < s.Max.Y && s.Min.Z < s.Max.Z, "Slabs[%d].Min (%v) must be less than Max (%v)", i, s.Min, s.Max)
+		check(s.Bounce >= 0, "Slabs[%d].Bounce (%v) must not be negative", i, s.Bounce)
+		check(s.Friction >= 0, "Slabs[%d].Friction (%v) must not be negative", i, s.Friction)
+	}
+	for i, p := range d.Bumpers {
+		check(p.Radius > 0, "Bumpers[%d].Radius (%v) must be positive", i, p.Radius)
+		check(p.Bounce >= 0, "Bumpers[%d].Bounce (%v) must not be negative", i, p.Bounce)
+		check(p.Friction >= 0, "Bumpers[%d].Friction (%v) must not be negative", i, p.Friction)
+	}
+	for i, w := range d.Wells {
+		check(w.Radius > 0, "Wells[%d].Radius (%v) must be positive", i, w.Radius)
+	}
+	for i, g := range d.Goals {
+		check(GOAL_FULL <= g.Shape && g.Shape < NUM_GOALS, "Goals[%d].Shape (%d) must be between %d and %d", i, g.Shape, GOAL_FULL, NUM_GOALS-1)
+		if g.Shape != GOAL_FULL {
+			check(g.Size.X > 0 && g.Size.Y > 0, "Goals[%d].Size (%v) must be positive", i, g.Size)
+			check(math.Abs(g.Center.X) < size.X && math.Abs(g.Center.Y) < size.Y, "Goals[%d].Center (%v) must be on the end wall", i, g.Center)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid arena:\n    " + strings.Join(errs, "\n    "))
+	}
+	return nil
+}
+
+// setArena makes d the current arena and rebuilds what the ball collides with.
+func (c *Game) setArena(d ArenaDef) {
+	c.ArenaDef = d
+	c.Arena = c.Config.Arena
+	if d.Size != (ga.Vec3d{}) {
+		c.Arena = d.Size
+	}
+
+	a := c.Arena
+	b := &d.Bounce
+	f := d.Friction
+	if f == (Friction{}) {
+		x := c.Config.Friction
+		f = Friction{x, x, x, x, x}
+	}
+	friction := func(x float64) float64 {
+		if x == 0 {
+			return c.Config.Friction
+		}
+		return x
+	}
+
+	// The side walls come first, they are goals when a
+	// player guards them in four player mode
+	c.Surfaces = append(c.Surfaces[:0],
+		Surface{Plane{ga.Vec3d{1, 0, 0}, -a.X}, b.Left, f.Left},
+		Surface{Plane{ga.Vec3d{-1, 0, 0}, -a.X}, b.Right, f.Right},
+		Surface{Plane{ga.Vec3d{0, 1, 0}, -a.Y}, b.Ceiling, f.Ceiling},
+		Surface{Plane{ga.Vec3d{0, -1, 0}, -a.Y}, b.Floor, f.Floor},
+	)
+	for _, s := range d.Slabs {
+		bounce := s.Bounce
+		if bounce == 0 {
+			bounce = 1
+		}
+		c.Surfaces = append(c.Surfaces, Surface{Box{s.Min, s.Max}, bounce, friction(s.Friction)})
+	}
+	for _, p := range d.Bumpers {
+		bounce := p.Bounce
+		if bounce == 0 {
+			bounce = BUMPER_BOUNCE
+		}
+		c.Surfaces = append(c.Surfaces, Surface{Sphere{p.Pos, p.Radius}, bounce, friction(p.Friction)})
+	}
+	c.EndWall = Surface{nil, b.End, f.End}
+	c.setNet(c.Net)
+}
+
+// inGoal reports whether x, y on the end wall of side is inside of its goal.
+func (c *Game) inGoal(side int, x, y float64) bool {
+	g := &c.ArenaDef.Goals[side]
+	dx := (x - g.Center.X)
+	dy := (y - g.Center.Y)
+	switch g.Shape {
+	case GOAL_RECT:
+		return math.Abs(dx) <= g.Size.X && math.Abs(dy) <= g.Size.Y
+	case GOAL_ELLIPSE:
+		dx /= g.Size.X
+		dy /= g.Size.Y
+		return dx*dx+dy*dy <= 1
+	}
+	return true
+}
+
+// drawArenaDef draws the slabs, bumpers, wells and goals of the arena.
+func (c *Game) drawArenaDef(pln int) {
+	d := &c.ArenaDef
+	for i, s := range d.Slabs {
+		c.drawBox(pln, s.Min, s.Max, c.editorColor(i, -1, slabColor))
+	}
+	for i, b := range d.Bumpers {
+		p := c.toView(pln, b.Pos)
+		col := c.editorColor(-1, i, bumperColor)
+		none := color.RGBA{}
+		c.drawRing(pln, PLANE_XY, p, b.Radius, col, none)
+		c.drawRing(pln, PLANE_XZ, p, b.Radius, col, none)
+		c.drawRing(pln, PLANE_YZ, p, b.Radius, col, none)
+	}
+	c.drawWells(pln)
+
+	for side, g := range d.Goals {
+		z := -c.Arena.Z
+		if side == 1 {
+			z = c.Arena.Z
+		}
+		switch g.Shape {
+		case GOAL_RECT:
+			x0, x1 := g.Center.X-g.Size.X, g.Center.X+g.Size.X
+			y0, y1 := g.Center.Y-g.Size.Y, g.Center.Y+g.Size.Y
+			pts := [4]ga.Vec3d{{x0, y0, z}, {x1, y0, z}, {x1, y1, z}, {x0, y1, z}}
+			for i := range pts {
+				c.drawLine(pln, c.toView(pln, pts[i]), c.toView(pln, pts[(i+1)%4]), goalColor)
+			}
+		case GOAL_ELLIPSE:
+			var pts [RING_SEGMENTS]ga.Vec3d
+			for i := range pts {
+				sin, cos := math.Sincos(2 * math.Pi * float64(i) / RING_SEGMENTS)
+				pts[i] = ga.Vec3d{g.Center.X + cos*g.Size.X, g.Center.Y + sin*g.Size.Y, z}
+			}
+			for i := range pts {
+				c.drawLine(pln, c.toView(pln, pts[i]), c.toView(pln, pts[(i+1)%len(pts)]), goalColor)
+			}
+		}
+	}
+}
+
+// A Shape is something the ball can collide with.
+type Shape interface {
+	// contact returns which way to push a ball of radius r at p
+	// out of the shape and how far, if it is touching the shape
+	contact(p ga.Vec3d, r float64) (ga.Vec3d, float64, bool)
+}
+
+// A Surface is a shape and how much speed the ball keeps bouncing off it,
+// more than 1 kicks the ball away faster than it came in, and how much
+// friction it has in the physical model.
+type Surface struct {
+	Shape    Shape
+	Bounce   float64
+	Friction float64
+}
+
+// A Plane keeps the ball on the side Normal points to,
+// it is at a distance Dist from the origin along Normal.
+type Plane struct {
+	Normal ga.Vec3d
+	Dist   float64
+}
+
+type Box struct {
+	Min ga.Vec3d
+	Max ga.Vec3d
+}
+
+type Sphere struct {
+	Center ga.Vec3d
+	Radius float64
+}
+
+func (s Plane) contact(p ga.Vec3d, r float64) (ga.Vec3d, float64, bool) {
+	d := vec3.Dot(s.Normal, p) - s.Dist
+	if d >= r {
+		return ga.Vec3d{}, 0, false
+	}
+	return s.Normal, r - d, true
+}
+
+func (s Box) contact(p ga.Vec3d, r float64) (ga.Vec3d, float64, bool) {
+	q := ga.Vec3d{
+		ga.Clamp(p.X, s.Min.X, s.Max.X),
+		ga.Clamp(p.Y, s.Min.Y, s.Max.Y),
+		ga.Clamp(p.Z, s.Min.Z, s.Max.Z),
+	}
+	d := vec3.Sub(p, q)
+	if l := vec3.Len(d); l > 0 {
+		if l >= r {
+			return ga.Vec3d{}, 0, false
+		}
+		return vec3.Scale(d, 1/l), r - l, true
+	}
+
+	// The center is inside of the box, push it out the nearest face
+	var n ga.Vec3d
+	depth := math.Inf(1)
+	faces := [...]struct {
+		Dist   float64
+		Normal ga.Vec3d
+	}{
+		{p.X - s.Min.X, ga.Vec3d{-1, 0, 0}},
+		{s.Max.X - p.X, ga.Vec3d{1, 0, 0}},
+		{p.Y - s.Min.Y, ga.Vec3d{0, -1, 0}},
+		{s.Max.Y - p.Y, ga.Vec3d{0, 1, 0}},
+		{p.Z - s.Min.Z, ga.Vec3d{0, 0, -1}},
+		{s.Max.Z - p.Z, ga.Vec3d{0, 0, 1}},
+	}
+	for _, f := range faces {
+		if f.Dist < depth {
+			depth, n = f.Dist, f.Normal
+		}
+	}
+	return n, depth + r, true
+}
+
+func (s Sphere) contact(p ga.Vec3d, r float64) (ga.Vec3d, float64, bool) {
+	d := vec3.Sub(p, s.Center)
+	l := vec3.Len(d)
+	if l >= r+s.Radius {
+		return ga.Vec3d{}, 0, false
+	}
+	if l == 0 {
+		return ga.Vec3d{0, -1, 0}, r + s.Radius, true
+	}
+	return vec3.Scale(d, 1/l), r + s.Radius - l, true
+}
+
+// collide pushes a ball at p moving at v out of every surface it touches
+// and bounces it off them, friction adds to spin. Hit is called with the
+// point and normal of each contact unless it is nil.
+func (c *Game) collide(p, v, spin *ga.Vec3d, hit func(p, n ga.Vec3d)) {
+	r := c.BallSize
+	for i, s := range c.Surfaces {
+		if i < 2 && c.guarded(2+i) && !c.front(2+i) {
+			continue
+		}
+		n, depth, ok := s.Shape.contact(*p, r)
+		if !ok {
+			continue
+		}
+		*p = vec3.Add(*p, vec3.Scale(n, depth))
+
+		// Only bounce if it is moving into the surface
+		vn := vec3.Dot(*v, n)
+		if vn >= 0 {
+			continue
+		}
+		c.bounceSurface(v, spin, n, vn, s)
+		if hit != nil {
+			hit(vec3.Sub(*p, vec3.Scale(n, r)), n)
+		}
+	}
+}
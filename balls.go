@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+const (
+	// Most balls that can be in play at once
+	MAX_BALLS = 4
+
+	// How much spin bends the path of a ball each tick,
+	// and how much of it is left on the next tick
+	SPIN_CURVE = 0.02
+	SPIN_DECAY = 0.98
+)
+
+// A Ball is one of the balls in play, a rally can have several
+// of them in multi-ball and each one keeps its own speed and spin.
+type Ball struct {
+	Pos   ga.Vec3d
+	Vel   ga.Vec3d
+	Speed float64
+	Spin  ga.Vec3d
+	Trail []TrailPoint
+
+	// Who hit it last, they get the power-ups it goes through
+	LastHit int
+
+	// Where it is going to cross a paddle plane and whose,
+	// worked out every tick for the crossing hint
+	Crossing  ga.Vec3d
+	CrossSeat int
+	Crosses   bool
+}
+
+// moveBalls moves every ball in play and drops the ones that got past
+// a paddle, the rally is over when the last one is gone. Every so many
+// paddle hits in a rally another ball splits off of the one that was hit.
+func (c *Game) moveBalls() {
+	if !c.BallInPlay {
+		return
+	}
+
+	var spawn []Ball
+	balls := c.Balls[:0]
+	for i := range c.Balls {
+		b := c.Balls[i]
+		rally := c.Rally
+		c.magnet(&b)
+		if !c.moveBall(&b) {
+			continue
+		}
+		balls = append(balls, b)
+
+		if c.Rally != rally && c.MultiBallRally > 0 && c.Rally%c.MultiBallRally == 0 &&
+			len(c.Balls)+len(spawn) < c.MultiBall {
+			spawn = append(spawn, c.splitBall(&b))
+		}
+	}
+	c.Balls = append(balls, spawn...)
+	c.predictCrossings()
+
+	if len(c.Balls) == 0 {
+		c.endRally()
+	}
+}
+
+// endRally is called once the last ball of a rally is out of play.
+func (c *Game) endRally() {
+	c.BallInPlay = false
+	c.Rally = 0
+	c.clearPowers()
+	if c.Mode == PRACTICE {
+		c.BallWaitingFor = LAUNCHER_SEAT
+	}
+	c.missRun()
+}
+
+// splitBall makes a new ball going off the other way from b.
+func (c *Game) splitBall(b *Ball) Ball {
+	n := Ball{
+		Pos:   b.Pos,
+		Vel:   ga.Vec3d{-b.Vel.X, -b.Vel.Y, b.Vel.Z},
+		Speed: b.Speed,
+		Spin:  ga.Vec3d{-b.Spin.X, -b.Spin.Y, b.Spin.Z},
+
+		LastHit: b.LastHit,
+	}
+
+	// A ball going straight would split into the same path
+	if n.Vel.X == 0 && n.Vel.Y == 0 {
+		n.Vel.X = float64(rand.Intn(int(b.Speed*2))) - b.Speed
+		n.Vel.Y = float64(rand.Intn(int(b.Speed*2))) - b.Speed
+	}
+	c.playSound("wall")
+	return n
+}
+
+// threat returns the ball that is going to reach the paddle
+// plane of a seat first, or the closest one if none are headed
+// there, nil if there are no balls in play.
+func (c *Game) threat(seat int) *Ball {
+	z := -c.paddleZ(seat)
+
+	var best *Ball
+	bestTime := math.Inf(1)
+	for i := range c.Balls {
+		b := &c.Balls[i]
+		v := -c.worldToSeat(seat, b.Vel).Z
+		d := z + c.worldToSeat(seat, b.Pos).Z
+
+		// Balls going away are only a threat after they come back
+		var t float64
+		switch {
+		case v > 0:
+			t = d / v
+		case v < 0:
+			t = (4*z - d) / -v
+		default:
+			t = math.MaxFloat64
+		}
+
+		if best == nil || t < bestTime {
+			best, bestTime = b, t
+		}
+	}
+	return best
+}
+
+// spinBall bends the path of a ball with its spin,
+// it wears off a bit every tick.
+func (c *Game) spinBall(b *Ball) {
+	b.Vel = vec3.Add(b.Vel, vec3.Scale(b.Spin, SPIN_CURVE))
+	b.Spin = vec3.Scale(b.Spin, SPIN_DECAY)
+	if vec3.Len(b.Spin) < 0.01 {
+		b.Spin = ga.Vec3d{}
+	}
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qeedquan/go-media/math/ga"
+)
+
+// Config holds every tunable of the game, it is loaded from a JSON file
+// and then overridden by the command line.
+type Config struct {
+	Mode       int
+	Layout     int
+	Width      int
+	Height     int
+	Fullscreen bool
+	Sound      bool
+	NoClick    [2]bool
+	TickRate   float64
+	UIScale    float64
+	LineWidth  float64
+	Antialias  bool
+	Solid      bool
+
+	Seats      [MAX_SEATS]int
+	Lives      int
+	Teams      bool
+	DoublesGap float64
+	Host       string
+	Join       string
+	JoinSeat   int
+
+	Shadow       bool
+	WallMarkers  bool
+	CrossingHint bool
+	TrailLength  int
+	ImpactTime   int
+	FlashTime    int
+
+	Arena              ga.Vec3d
+	ArenaFile          string
+	Paddle             ga.Vec2d
+	BallSize           float64
+	BallSpeed          float64
+	Physics            int
+	Drag               float64
+	Restitution        float64
+	Friction           float64
+	MaxSpeed           float64
+	MultiBall          int
+	MultiBallRally     int
+	PowerUps           bool
+	PowerUpInterval    float64
+	Gravity            float64
+	MinHandballGravity float64
+	GravityMode        int
+	GravityDir         ga.Vec3d
+	GravityPeriod      float64
+	Drill              int
+	DrillScript        []Shot
+	LaunchSpeed        float64
+	LaunchSpin         ga.Vec2d
+	LaunchHeight       float64
+	LaunchInterval     float64
+	Challenge          int
+	Variant            int
+	Targets            int
+	TargetSize         float64
+	TargetSpeed        float64
+	TargetPoints       int
+	ChallengeTime      float64
+	Net                float64
+	Obstacles          int
+	ObstacleSize       float64
+	ObstacleSpeed      float64
+	ObstaclePath       int
+	PaddleModel        int
+	ComputerSpeed      float64
+	AngleDivide        float64
+	ShimmerTime        int
+	QueueSize          int
+
+	Distance    float64
+	Aspect      float64
+	Stereo      int
+	IPD         float64
+	Convergence float64
+
+	DebrisTime  int
+	DebrisMin   int
+	DebrisMax   int
+	DebrisSpeed int
+
+	Colors [2][10]Color
+}
+
+// Color is a color.RGBA that is written as #rrggbb or #rrggbbaa in the config.
+type Color color.RGBA
+
+func DefaultConfig() Config {
+	const (
+		X_WIDTH  = 100
+		Y_HEIGHT = 100
+		Z_DEPTH  = 150
+
+		PADDLE_WIDTH  = 25
+		PADDLE_HEIGHT = 25
+
+		BALL_SPEED = 2
+
+		MULTI_BALL_RALLY = 5
+
+		DRAG        = 0.002
+		RESTITUTION = 0.9
+		FRICTION    = 0.2
+		MAX_SPEED   = 20
+
+		POWERUP_INTERVAL = 10
+
+		GRAVITY_PERIOD = 10
+
+		LAUNCH_SPEED    = 4
+		LAUNCH_INTERVAL = 1.5
+
+		TARGETS        = 3
+		TARGET_SIZE    = 15
+		TARGET_SPEED   = 1
+		TARGET_POINTS  = 5
+		CHALLENGE_TIME = 60
+
+		OBSTACLE_SIZE  = 15
+		OBSTACLE_SPEED = 1
+
+		IPD      = 20
+		DISTANCE = Z_DEPTH + 100
+		ASPECT   = 200
+
+		TRAIL_LENGTH = 16
+		IMPACT_TIME  = 15
+		FLASH_TIME   = 10
+
+		DEBRIS_TIME  = 50
+		DEBRIS_MIN   = 5
+		DEBRIS_MAX   = 10
+		DEBRIS_SPEED = 2
+
+		MIN_HANDBALL_GRAVITY = 0.25
+		COMPUTER_SPEED       = 5
+		BALL_SIZE            = 15
+		ANGLE_DIVIDE         = 3
+
+		SHIMMER_TIME = 5
+
+		LIVES = 3
+
+		DOUBLES_GAP = Z_DEPTH / 2
+
+		QUEUE_SIZE = 5
+
+		TICK_RATE = 1000 / 80.0
+	)
+
+	colors := [10]Color{
+		// red
+		{255, 0, 0, 255},
+		// blue
+		{0, 0, 255, 255},
+		// green
+		{0, 255, 0, 255},
+		// darkred
+		{139, 0, 0, 255},
+		// darkblue
+		{0, 0, 139, 255},
+		// darkgreen
+		{0, 139, 0, 255},
+		// lightred, for the front players in doubles
+		{255, 128, 128, 255},
+		// lightblue
+		{128, 128, 255, 255},
+		// rosybrown
+		{197, 128, 128, 255},
+		// slateblue
+		{128, 128, 197, 255},
+	}
+
+	return Config{
+		Mode:               HANDBALL,
+		Seats:              [MAX_SEATS]int{SEAT_HUMAN, SEAT_AI, SEAT_AI, SEAT_AI},
+		Lives:              LIVES,
+		DoublesGap:         DOUBLES_GAP,
+		JoinSeat:           1,
+		Width:              580,
+		Height:             580,
+		Sound:              true,
+		Shadow:             true,
+		TrailLength:        TRAIL_LENGTH,
+		ImpactTime:         IMPACT_TIME,
+		FlashTime:          FLASH_TIME,
+		TickRate:           TICK_RATE,
+		Arena:              ga.Vec3d{X_WIDTH, Y_HEIGHT, Z_DEPTH},
+		ArenaFile:          "classic",
+		Paddle:             ga.Vec2d{PADDLE_WIDTH, PADDLE_HEIGHT},
+		BallSize:           BALL_SIZE,
+		BallSpeed:          BALL_SPEED,
+		Drag:               DRAG,
+		Restitution:        RESTITUTION,
+		Friction:           FRICTION,
+		MaxSpeed:           MAX_SPEED,
+		MultiBall:          1,
+		MultiBallRally:     MULTI_BALL_RALLY,
+		PowerUpInterval:    POWERUP_INTERVAL,
+		ObstacleSize:       OBSTACLE_SIZE,
+		ObstacleSpeed:      OBSTACLE_SPEED,
+		MinHandballGravity: MIN_HANDBALL_GRAVITY,
+		GravityDir:         ga.Vec3d{0, 1, 0},
+		GravityPeriod:      GRAVITY_PERIOD,
+		DrillScript:        defaultDrillScript,
+		LaunchSpeed:        LAUNCH_SPEED,
+		LaunchInterval:     LAUNCH_INTERVAL,
+		Targets:            TARGETS,
+		TargetSize:         TARGET_SIZE,
+		TargetSpeed:        TARGET_SPEED,
+		TargetPoints:       TARGET_POINTS,
+		ChallengeTime:      CHALLENGE_TIME,
+		ComputerSpeed:      COMPUTER_SPEED,
+		AngleDivide:        ANGLE_DIVIDE,
+		ShimmerTime:        SHIMMER_TIME,
+		QueueSize:          QUEUE_SIZE,
+		Distance:           DISTANCE,
+		Aspect:             ASPECT,
+		IPD:                IPD,
+		Convergence:        DISTANCE,
+		DebrisTime:         DEBRIS_TIME,
+		DebrisMin:          DEBRIS_MIN,
+		DebrisMax:          DEBRIS_MAX,
+		DebrisSpeed:        DEBRIS_SPEED,
+		Colors:             [2][10]Color{colors, colors},
+	}
+}
+
+// configPath returns the path of a file in the per-user config directory.
+func configPath(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return name
+	}
+	return filepath.Join(dir, "3dpong", name)
+}
+
+// Load reads name on top of the existing settings,
+// so a file only needs to list what it changes.
+func (f *Config) Load(name string) error {
+	fd, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	dec := json.NewDecoder(fd)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	// Files from before the colors were added leave them out
+	d := DefaultConfig()
+	for i := range f.Colors {
+		for j := range f.Colors[i] {
+			if f.Colors[i][j] == (Color{}) {
+				f.Colors[i][j] = d.Colors[i][j]
+			}
+		}
+	}
+	return nil
+}
+
+func (f *Config) Dump() error {
+	buf, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", buf)
+	return err
+}
+
+func (f *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(HANDBALL <= f.Mode && f.Mode <= PRACTICE, "Mode (%d) must be between %d and %d", f.Mode, HANDBALL, PRACTICE)
+	humans := 0
+	for i, t := range f.Seats {
+		check(SEAT_HUMAN <= t && t < NUM_SEAT_TYPES, "Seats[%d] (%d) must be between %d and %d", i, t, SEAT_HUMAN, NUM_SEAT_TYPES-1)
+		if t == SEAT_HUMAN {
+			humans++
+		}
+	}
+	check(humans <= 2, "Seats can have at most 2 human players, got %d", humans)
+	check(f.Lives >= 1, "Lives (%d) must be at least 1", f.Lives)
+	check(f.Host == "" || f.Join == "", "Host and Join can not both be set")
+	check(0 <= f.JoinSeat && f.JoinSeat < MAX_SEATS, "JoinSeat (%d) must be between 0 and %d", f.JoinSeat, MAX_SEATS-1)
+	check(LAYOUT_SIDE_BY_SIDE <= f.Layout && f.Layout <= LAYOUT_PICTURE_IN_PICTURE, "Layout (%d) must be between %d and %d", f.Layout, LAYOUT_SIDE_BY_SIDE, LAYOUT_PICTURE_IN_PICTURE)
+	check(f.Width > 0 && f.Height > 0, "Width and Height (%dx%d) must be positive", f.Width, f.Height)
+	check(f.UIScale >= 0, "UIScale (%v) must not be negative", f.UIScale)
+	check(f.LineWidth >= 0, "LineWidth (%v) must not be negative", f.LineWidth)
+	check(f.TickRate > 0, "TickRate (%v) must be positive", f.TickRate)
+
+	errs = append(errs, f.fitArena("Arena", f.Arena)...)
+	check(f.BallSpeed >= 1, "BallSpeed (%v) must be at least 1", f.BallSpeed)
+	check(PHYSICS_ARCADE <= f.Physics && f.Physics < NUM_PHYSICS, "Physics (%d) must be between %d and %d", f.Physics, PHYSICS_ARCADE, NUM_PHYSICS-1)
+	check(0 <= f.Drag && f.Drag < 1, "Drag (%v) must be between 0 and 1", f.Drag)
+	check(0 <= f.Restitution && f.Restitution <= 1, "Restitution (%v) must be between 0 and 1", f.Restitution)
+	check(f.Friction >= 0, "Friction (%v) must not be negative", f.Friction)
+	check(f.MaxSpeed >= f.BallSpeed, "MaxSpeed (%v) must be at least BallSpeed (%v)", f.MaxSpeed, f.BallSpeed)
+	check(1 <= f.MultiBall && f.MultiBall <= MAX_BALLS, "MultiBall (%d) must be between 1 and %d", f.MultiBall, MAX_BALLS)
+	check(f.MultiBallRally >= 0, "MultiBallRally (%d) must not be negative", f.MultiBallRally)
+	check(f.PowerUpInterval > 0, "PowerUpInterval (%v) must be positive", f.PowerUpInterval)
+	check(f.MinHandballGravity >= 0, "MinHandballGravity (%v) must not be negative", f.MinHandballGravity)
+	check(GRAVITY_CLASSIC <= f.GravityMode && f.GravityMode < NUM_GRAVITY_MODES, "GravityMode (%d) must be between %d and %d", f.GravityMode, GRAVITY_CLASSIC, NUM_GRAVITY_MODES-1)
+	check(f.GravityMode != GRAVITY_VECTOR || f.GravityDir != (ga.Vec3d{}), "GravityDir (%v) must not be zero", f.GravityDir)
+	check(f.GravityPeriod > 0, "GravityPeriod (%v) must be positive", f.GravityPeriod)
+	check(DRILL_RANDOM <= f.Drill && f.Drill < NUM_DRILLS, "Drill (%d) must be between %d and %d", f.Drill, DRILL_RANDOM, NUM_DRILLS-1)
+	check(f.Drill != DRILL_SCRIPT || len(f.DrillScript) > 0, "DrillScript must have a shot for the script drill")
+	for i, s := range f.DrillScript {
+		check(math.Abs(s.Target.X) <= 1 && math.Abs(s.Target.Y) <= 1, "DrillScript[%d].Target (%v) must be between -1 and 1", i, s.Target)
+		check(math.Abs(s.Return.X) <= 1 && math.Abs(s.Return.Y) <= 1, "DrillScript[%d].Return (%v) must be between -1 and 1", i, s.Return)
+		check(math.Abs(s.Height) <= 1, "DrillScript[%d].Height (%v) must be between -1 and 1", i, s.Height)
+		check(s.Speed >= 0, "DrillScript[%d].Speed (%v) must not be negative", i, s.Speed)
+	}
+	check(f.LaunchSpeed > 0, "LaunchSpeed (%v) must be positive", f.LaunchSpeed)
+	check(math.Abs(f.LaunchHeight) <= 1, "LaunchHeight (%v) must be between -1 and 1", f.LaunchHeight)
+	check(f.LaunchInterval >= 0, "LaunchInterval (%v) must not be negative", f.LaunchInterval)
+	check(CHALLENGE_CLASSIC <= f.Challenge && f.Challenge < NUM_CHALLENGES, "Challenge (%d) must be between %d and %d", f.Challenge, CHALLENGE_CLASSIC, NUM_CHALLENGES-1)
+	check(f.Targets >= 0, "Targets (%d) must not be negative", f.Targets)
+	check(f.TargetSpeed >= 0, "TargetSpeed (%v) must not be negative", f.TargetSpeed)
+	check(f.TargetPoints >= 0, "TargetPoints (%d) must not be negative", f.TargetPoints)
+	check(VARIANT_CLASSIC <= f.Variant && f.Variant < NUM_VARIANTS, "Variant (%d) must be between %d and %d", f.Variant, VARIANT_CLASSIC, NUM_VARIANTS-1)
+	check(f.ChallengeTime > 0, "ChallengeTime (%v) must be positive", f.ChallengeTime)
+	check(f.Obstacles >= 0, "Obstacles (%d) must not be negative", f.Obstacles)
+	check(f.ObstacleSpeed >= 0, "ObstacleSpeed (%v) must not be negative", f.ObstacleSpeed)
+	check(PATH_PERIMETER <= f.ObstaclePath && f.ObstaclePath < NUM_PATHS, "ObstaclePath (%d) must be between %d and %d", f.ObstaclePath, PATH_PERIMETER, NUM_PATHS-1)
+	check(PADDLE_FLAT <= f.PaddleModel && f.PaddleModel < NUM_PADDLE_MODELS, "PaddleModel (%d) must be between %d and %d", f.PaddleModel, PADDLE_FLAT, NUM_PADDLE_MODELS-1)
+	check(f.ComputerSpeed >= 0, "ComputerSpeed (%v) must not be negative", f.ComputerSpeed)
+	check(f.AngleDivide > 0, "AngleDivide (%v) must be positive", f.AngleDivide)
+	check(f.ShimmerTime >= 0, "ShimmerTime (%d) must not be negative", f.ShimmerTime)
+	check(f.QueueSize > 0, "QueueSize (%d) must be positive", f.QueueSize)
+
+	check(f.TrailLength >= 0, "TrailLength (%d) must not be negative", f.TrailLength)
+	check(f.ImpactTime >= 0, "ImpactTime (%d) must not be negative", f.ImpactTime)
+	check(f.FlashTime >= 0, "FlashTime (%d) must not be negative", f.FlashTime)
+
+	check(f.Aspect > 0, "Aspect (%v) must be positive", f.Aspect)
+	check(STEREO_OFF <= f.Stereo && f.Stereo < NUM_STEREO, "Stereo (%d) must be between %d and %d", f.Stereo, STEREO_OFF, NUM_STEREO-1)
+	check(f.IPD >= 0, "IPD (%v) must not be negative", f.IPD)
+	check(f.Convergence > 0, "Convergence (%v) must be positive", f.Convergence)
+
+	check(f.DebrisTime > 0, "DebrisTime (%d) must be positive", f.DebrisTime)
+	check(f.DebrisMin >= 0, "DebrisMin (%d) must not be negative", f.DebrisMin)
+	check(f.DebrisMax > f.DebrisMin, "DebrisMax (%d) must be greater than DebrisMin (%d)", f.DebrisMax, f.DebrisMin)
+	check(f.DebrisSpeed > 0, "DebrisSpeed (%d) must be positive", f.DebrisSpeed)
+
+	if len(errs) > 0 {
+		return errors.New("invalid config:\n    " + strings.Join(errs, "\n    "))
+	}
+	return nil
+}
+
+// fitArena checks the settings that depend on the size of the arena
+// against a, name is what the size is called in the errors.
+func (f *Config) fitArena(name string, a ga.Vec3d) []string {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(a.X > 0 && a.Y > 0 && a.Z > 0, "%s (%v) must be positive in every dimension", name, a)
+	check(0 < f.Paddle.X && f.Paddle.X < a.X, "Paddle.X (%v) must be between 0 and %s.X (%v)", f.Paddle.X, name, a.X)
+	check(0 < f.Paddle.Y && f.Paddle.Y < a.Y, "Paddle.Y (%v) must be between 0 and %s.Y (%v)", f.Paddle.Y, name, a.Y)
+	check(0 < f.BallSize && f.BallSize < minComponent(a), "BallSize (%v) must be positive and smaller than %s (%v)", f.BallSize, name, a)
+	check(f.BallSize < f.DoublesGap && f.DoublesGap < a.Z, "DoublesGap (%v) must be between BallSize (%v) and %s.Z (%v)", f.DoublesGap, f.BallSize, name, a.Z)
+	check(0 < f.TargetSize && f.TargetSize < math.Min(a.X, a.Y), "TargetSize (%v) must be positive and smaller than the back wall of %s (%v)", f.TargetSize, name, a)
+	check(0 <= f.Net && f.Net < 2*a.Y, "Net (%v) must be between 0 and the height of %s (%v)", f.Net, name, 2*a.Y)
+	check(0 < f.ObstacleSize && f.ObstacleSize < minComponent(a)/2, "ObstacleSize (%v) must be positive and smaller than half of %s (%v)", f.ObstacleSize, name, a)
+	check(f.Distance > a.Z, "Distance (%v) must be greater than %s.Z (%v)", f.Distance, name, a.Z)
+	return errs
+}
+
+func minComponent(v ga.Vec3d) float64 {
+	m := v.X
+	if v.Y < m {
+		m = v.Y
+	}
+	if v.Z < m {
+		m = v.Z
+	}
+	return m
+}
+
+// applyConfig copies the settings into the game state.
+func (c *Game) applyConfig() {
+	f := &c.Config
+
+	c.Mode = f.Mode
+	c.Teams = f.Teams
+	c.DoublesGap = f.DoublesGap
+	c.assignSeats()
+	c.Layout = f.Layout
+	c.Width, c.Height = c.windowSize()
+	c.layout()
+	c.Fullscreen = f.Fullscreen
+	c.Sound = f.Sound
+	c.Antialias = f.Antialias
+	c.Shadow = f.Shadow
+	c.WallMarkers = f.WallMarkers
+	c.CrossingHint = f.CrossingHint
+	c.TrailLength = f.TrailLength
+	c.ImpactTime = f.ImpactTime
+	c.FlashTime = f.FlashTime
+	c.NoClick = f.NoClick
+
+	c.PaddleSize = f.Paddle
+	c.BallSize = f.BallSize
+	c.InitialBallSpeed = f.BallSpeed
+	c.Physics = f.Physics
+	c.Drag = f.Drag
+	c.Restitution = f.Restitution
+	c.MaxSpeed = f.MaxSpeed
+	c.MultiBall = f.MultiBall
+	c.MultiBallRally = f.MultiBallRally
+	c.PowerUps = f.PowerUps
+	c.PowerUpInterval = f.PowerUpInterval
+	c.MinHandballGravity = f.MinHandballGravity
+	c.GravityMode = f.GravityMode
+	c.GravityPeriod = f.GravityPeriod
+	c.setGravity()
+	c.Drill = f.Drill
+	c.LaunchInterval = f.LaunchInterval
+	c.Challenge = f.Challenge
+	c.Variant = f.Variant
+	c.TargetCount = f.Targets
+	c.TargetSize = f.TargetSize
+	c.TargetSpeed = f.TargetSpeed
+	c.TargetPoints = f.TargetPoints
+	c.ChallengeTime = f.ChallengeTime
+	c.setNet(f.Net)
+
+	arena, err := c.loadArena(f.ArenaFile, f.Arena)
+	ck(err)
+	ck(arena.Validate(f))
+	c.setArena(arena)
+	c.ObstacleCount = f.Obstacles
+	c.ObstacleSize = f.ObstacleSize
+	c.ObstacleSpeed = f.ObstacleSpeed
+	c.ObstaclePath = f.ObstaclePath
+	c.PaddleModel = f.PaddleModel
+	c.ComputerSpeed = f.ComputerSpeed
+	c.AngleDivide = f.AngleDivide
+	c.ShimmerTime = f.ShimmerTime
+	c.newGame()
+
+	c.Distance = f.Distance
+	c.Aspect = f.Aspect
+	c.IPD = f.IPD
+	c.Convergence = f.Convergence
+
+	c.DebrisTime = f.DebrisTime
+	c.DebrisMin = f.DebrisMin
+	c.DebrisMax = f.DebrisMax
+	c.DebrisSpeed = f.DebrisSpeed
+
+	for i := range f.Colors {
+		for j := range f.Colors[i] {
+			c.Colors[i][j] = color.RGBA(f.Colors[i][j])
+		}
+	}
+
+	for i := range c.Queue {
+		c.Queue[i] = make([]ga.Vec2d, f.QueueSize)
+		c.QueuePos[i] = 0
+	}
+
+	c.Gravity = math.Abs(f.Gravity)
+	if c.Gravity < c.MinHandballGravity {
+		c.Gravity = c.MinHandballGravity
+	}
+
+	if c.Ticker != nil {
+		c.Ticker.Stop()
+	}
+	c.Ticker = time.NewTicker(time.Duration(float64(time.Second) / f.TickRate))
+}
+
+func (l Color) MarshalText() ([]byte, error) {
+	if l.A == 255 {
+		return []byte(fmt.Sprintf("#%02x%02x%02x", l.R, l.G, l.B)), nil
+	}
+	return []byte(fmt.Sprintf("#%02x%02x%02x%02x", l.R, l.G, l.B, l.A)), nil
+}
+
+func (l *Color) UnmarshalText(text []byte) error {
+	s := string(text)
+	*l = Color{A: 255}
+
+	var n int
+	var err error
+	switch len(s) {
+	case 7:
+		n, err = fmt.Sscanf(s, "#%02x%02x%02x", &l.R, &l.G, &l.B)
+	case 9:
+		n, err = fmt.Sscanf(s, "#%02x%02x%02x%02x", &l.R, &l.G, &l.B, &l.A)
+	}
+	if n == 0 || err != nil {
+		return fmt.Errorf("invalid color %q, want #rrggbb or #rrggbbaa", s)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qeedquan/go-media/sdl"
+	"github.com/qeedquan/go-media/sdl/sdlimage/sdlcolor"
+)
+
+const (
+	ACTION_MOVE = iota
+	ACTION_ORBIT
+	ACTION_SERVE
+	ACTION_VIEW
+	ACTION_STEREO
+	ACTION_SOLID
+	ACTION_NOCLICK
+	ACTION_RESET
+	ACTION_PAUSE
+	ACTION_QUIT
+	ACTION_MENU
+	ACTION_REBIND
+	ACTION_EDITOR
+	NUM_ACTIONS
+)
+
+var actions = [NUM_ACTIONS]struct {
+	Name string
+	Desc string
+}{
+	{"move", "Move paddle (hold)"},
+	{"orbit", "Orbit the FreeView camera (hold)"},
+	{"serve", "Serve the ball"},
+	{"view", "Change view"},
+	{"stereo", "Cycle 3D stereo mode"},
+	{"solid", "Toggle solid or wireframe rendering"},
+	{"noclick", "Toggle \"noclick\" mode"},
+	{"reset", "Reset the game"},
+	{"pause", "Pause"},
+	{"quit", "Quit"},
+	{"menu", "Open the menu"},
+	{"rebind", "Change controls"},
+	{"editor", "Open the arena editor"},
+}
+
+var mouseButtons = map[int]string{
+	sdl.BUTTON_LEFT:   "Left",
+	sdl.BUTTON_MIDDLE: "Middle",
+	sdl.BUTTON_RIGHT:  "Right",
+}
+
+// A Binding is either a key or a mouse button.
+type Binding struct {
+	Key    sdl.Keycode
+	Button int
+}
+
+// A KeyMap holds the bindings of every action for one player.
+type KeyMap [NUM_ACTIONS][]Binding
+
+type Rebind struct {
+	Active  bool
+	Player  int
+	Cursor  int
+	Waiting bool
+}
+
+func defaultKeyMap() KeyMap {
+	var k KeyMap
+	k[ACTION_MOVE] = []Binding{{Button: sdl.BUTTON_LEFT}}
+	k[ACTION_ORBIT] = []Binding{{Button: sdl.BUTTON_MIDDLE}}
+	k[ACTION_SERVE] = []Binding{{Button: sdl.BUTTON_RIGHT}}
+	k[ACTION_VIEW] = []Binding{{Key: sdl.K_v}}
+	k[ACTION_STEREO] = []Binding{{Key: sdl.K_3}}
+	k[ACTION_SOLID] = []Binding{{Key: sdl.K_s}}
+	k[ACTION_NOCLICK] = []Binding{{Key: sdl.K_c}}
+	k[ACTION_RESET] = []Binding{{Key: sdl.K_r}}
+	k[ACTION_PAUSE] = []Binding{{Key: sdl.K_SPACE}, {Key: sdl.K_RETURN}}
+	k[ACTION_QUIT] = []Binding{{Key: sdl.K_q}}
+	k[ACTION_MENU] = []Binding{{Key: sdl.K_ESCAPE}}
+	k[ACTION_REBIND] = []Binding{{Key: sdl.K_F1}}
+	k[ACTION_EDITOR] = []Binding{{Key: sdl.K_F2}}
+	return k
+}
+
+func (b Binding) String() string {
+	if b.Button != 0 {
+		name, found := mouseButtons[b.Button]
+		if !found {
+			name = fmt.Sprint(b.Button)
+		}
+		return "Mouse " + name
+	}
+	return sdl.GetKeyName(b.Key)
+}
+
+func (b Binding) MarshalText() ([]byte, error) {
+	if b.Button != 0 {
+		return []byte(strings.Replace(b.String(), " ", ":", 1)), nil
+	}
+	return []byte(b.String()), nil
+}
+
+func (b *Binding) UnmarshalText(text []byte) error {
+	s := string(text)
+	if strings.HasPrefix(s, "Mouse:") {
+		name := strings.TrimPrefix(s, "Mouse:")
+		for button, bname := range mouseButtons {
+			if strings.EqualFold(name, bname) {
+				*b = Binding{Button: button}
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown mouse button %q", name)
+	}
+
+	key := sdl.GetKeyFromName(s)
+	if key == sdl.K_UNKNOWN {
+		return fmt.Errorf("unknown key %q", s)
+	}
+	*b = Binding{Key: key}
+	return nil
+}
+
+func (k *KeyMap) Key(action int, sym sdl.Keycode) bool {
+	return k.Match(action, Binding{Key: sym})
+}
+
+func (k *KeyMap) Button(action int, button int) bool {
+	return k.Match(action, Binding{Button: button})
+}
+
+func (k *KeyMap) Match(action int, b Binding) bool {
+	for _, kb := range k[action] {
+		if kb == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *KeyMap) Describe(action int) string {
+	var s []string
+	for _, b := range k[action] {
+		s = append(s, "["+b.String()+"]")
+	}
+	if len(s) == 0 {
+		return "(unbound)"
+	}
+	return strings.Join(s, " ")
+}
+
+func usageControls() {
+	fmt.Fprintln(os.Stderr, "Controls:")
+	for a := range actions {
+		text := game.Keys[0].Describe(a)
+		if p2 := game.Keys[1].Describe(a); p2 != text {
+			text += " (player 2: " + p2 + ")"
+		}
+		fmt.Fprintf(os.Stderr, "    %s %s\n", text, actions[a].Desc)
+	}
+}
+
+func (c *Game) LoadKeyMap() error {
+	buf, err := os.ReadFile(c.KeyMapFile)
+	if err != nil {
+		return err
+	}
+
+	var m map[string]map[string][]Binding
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.KeyMapFile, err)
+	}
+
+	for name, am := range m {
+		var pln int
+		_, err := fmt.Sscanf(name, "player%d", &pln)
+		if err != nil || pln < 1 || pln > len(c.Keys) {
+			return fmt.Errorf("%s: unknown player %q", c.KeyMapFile, name)
+		}
+
+		for aname, b := range am {
+			a := actionIndex(aname)
+			if a < 0 {
+				return fmt.Errorf("%s: unknown action %q", c.KeyMapFile, aname)
+			}
+			c.Keys[pln-1][a] = b
+		}
+	}
+	return nil
+}
+
+func (c *Game) SaveKeyMap() error {
+	m := make(map[string]map[string][]Binding)
+	for i := range c.Keys {
+		am := make(map[string][]Binding)
+		for a := range actions {
+			am[actions[a].Name] = c.Keys[i][a]
+		}
+		m[fmt.Sprintf("player%d", i+1)] = am
+	}
+
+	buf, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.KeyMapFile), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.KeyMapFile, buf, 0644)
+}
+
+func actionIndex(name string) int {
+	for a := range actions {
+		if actions[a].Name == name {
+			return a
+		}
+	}
+	return -1
+}
+
+// anyKey reports whether any player has sym bound to action,
+// used for the actions that are not tied to a viewport.
+func (c *Game) anyKey(action int, sym sdl.Keycode) bool {
+	for i := range c.Keys {
+		if c.Keys[i].Key(action, sym) {
+			return true
+		}
+	}
+	return false
+}
+
+// hold tracks the actions that last as long as their binding is held down.
+func (c *Game) hold(pln int, b Binding, down bool) {
+	for _, a := range []int{ACTION_MOVE, ACTION_ORBIT} {
+		if c.Keys[pln].Match(a, b) {
+			c.Held[pln][a] = down
+		}
+	}
+}
+
+// trigger performs the one shot actions bound to b.
+func (c *Game) trigger(pln int, b Binding) {
+	k := &c.Keys[pln]
+	switch {
+	case k.Match(ACTION_STEREO, b):
+		c.Glasses[pln] = (c.Glasses[pln] + 1) % NUM_STEREO
+	case k.Match(ACTION_SOLID, b):
+		c.Solid[pln] = !c.Solid[pln]
+	case k.Match(ACTION_VIEW, b):
+		c.View[pln] = (c.View[pln] + 1) % 6
+	case k.Match(ACTION_NOCLICK, b):
+		c.NoClick[pln] = !c.NoClick[pln]
+	case k.Match(ACTION_RESET, b):
+		c.reset()
+	case k.Match(ACTION_REBIND, b):
+		c.Rebind = Rebind{Active: true, Player: pln}
+	case k.Match(ACTION_SERVE, b):
+		// If the ball wasn't in play, this person launched it
+		seat := c.ViewSeat[pln]
+		if !c.BallInPlay && c.BallWaitingFor == seat {
+			if c.Link != nil && !c.Link.Host {
+				c.Link.Serve = true
+			} else {
+				c.putBallInPlay(seat)
+			}
+		}
+	}
+}
+
+func (c *Game) rebindEvent(ev interface{}) {
+	r := &c.Rebind
+	k := &c.Keys[r.Player]
+
+	if r.Waiting {
+		switch ev := ev.(type) {
+		case sdl.KeyDownEvent:
+			if ev.Sym != sdl.K_ESCAPE {
+				k[r.Cursor] = []Binding{{Key: ev.Sym}}
+			}
+			r.Waiting = false
+		case sdl.MouseButtonDownEvent:
+			k[r.Cursor] = []Binding{{Button: int(ev.Button)}}
+			r.Waiting = false
+		}
+		return
+	}
+
+	switch ev := ev.(type) {
+	case sdl.QuitEvent:
+		c.Quit = true
+	case sdl.KeyDownEvent:
+		switch ev.Sym {
+		case sdl.K_UP:
+			r.Cursor = (r.Cursor + NUM_ACTIONS - 1) % NUM_ACTIONS
+		case sdl.K_DOWN:
+			r.Cursor = (r.Cursor + 1) % NUM_ACTIONS
+		case sdl.K_TAB:
+			if c.Viewports == 2 {
+				r.Player = 1 - r.Player
+			}
+		case sdl.K_RETURN:
+			r.Waiting = true
+		case sdl.K_BACKSPACE, sdl.K_DELETE:
+			k[r.Cursor] = nil
+		case sdl.K_ESCAPE:
+			r.Active = false
+			ek(c.SaveKeyMap())
+		}
+	}
+}
+
+func (c *Game) drawRebind(pln int) {
+	r := &c.Rebind
+	if !r.Active || r.Player != pln {
+		return
+	}
+
+	x := c.px(TEXT_MARGIN)
+	fh := c.FontHeight
+	y := fh * 4
+	c.drawText(pln, x, y, sdlcolor.White, "Controls for player %d", pln+1)
+	y += fh * 2
+	for a := range actions {
+		col := c.Colors[pln][5]
+		prefix := "  "
+		if a == r.Cursor {
+			col = c.Colors[pln][2]
+			prefix = "> "
+		}
+		c.drawText(pln, x, y, col, "%s%-10s %s", prefix, actions[a].Name, c.Keys[pln].Describe(a))
+		y += fh
+	}
+
+	y += fh
+	if r.Waiting {
+		c.drawText(pln, x, y, sdlcolor.White, "Press a key or mouse button")
+	} else {
+		c.drawText(pln, x, y, sdlcolor.White, "Return: bind  Del: clear  Esc: done")
+		if c.Viewports == 2 {
+			c.drawText(pln, x, y+fh, sdlcolor.White, "Tab: other player")
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+const (
+	// Points around the rings drawn for the cues
+	RING_SEGMENTS = 12
+
+	// How much bigger the shadow is when the ball is at the ceiling
+	SHADOW_GROWTH = 2
+
+	// Opacity of the filled shadow in solid mode
+	SHADOW_ALPHA = 96
+
+	// Longest the crossing prediction looks ahead, in ticks
+	PREDICT_TICKS = 1000
+)
+
+// Axes of the plane a ring is drawn in
+const (
+	PLANE_XY = iota
+	PLANE_XZ
+	PLANE_YZ
+)
+
+// drawRing draws a circle of radius r around center in the given plane,
+// in solid mode it is filled with fill if its alpha is not zero.
+func (c *Game) drawRing(pln, plane int, center ga.Vec3d, r float64, col, fill color.RGBA) {
+	var pts [RING_SEGMENTS]ga.Vec3d
+	for i := range pts {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / RING_SEGMENTS)
+		p := center
+		switch plane {
+		case PLANE_XY:
+			p.X += cos * r
+			p.Y += sin * r
+		case PLANE_XZ:
+			p.X += cos * r
+			p.Z += sin * r
+		case PLANE_YZ:
+			p.Y += cos * r
+			p.Z += sin * r
+		}
+		pts[i] = p
+	}
+
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		if fill.A != 0 {
+			c.drawFace(pln, fill, center, pts[i], pts[j])
+		}
+		c.drawLine(pln, pts[i], pts[j], col)
+	}
+}
+
+// drawBallCues draws the hints showing where a ball is in the arena,
+// each one falls back to the original marker lines when turned off.
+func (c *Game) drawBallCues(pln int, ball *Ball) {
+	b := ball.Pos
+	s := c.BallSize
+	x := c.Arena.X
+	y := c.Arena.Y
+	col := c.Colors[pln][5]
+	line := func(p, q ga.Vec3d) {
+		c.drawLine(pln, c.toView(pln, p), c.toView(pln, q), col)
+	}
+	ring := func(p, n ga.Vec3d, r float64, fill color.RGBA) {
+		c.drawRing(pln, c.viewPlane(pln, n), c.toView(pln, p), r, col, fill)
+	}
+
+	if c.Shadow {
+		// Higher up the ball is, the bigger its shadow
+		h := (y - b.Y) / (2 * y)
+		r := s * (1 + h*SHADOW_GROWTH)
+		ring(ga.Vec3d{b.X, y, b.Z}, ga.Vec3d{0, 1, 0}, r, color.RGBA{0, 0, 0, SHADOW_ALPHA})
+	} else {
+		line(ga.Vec3d{b.X - s, y, b.Z}, ga.Vec3d{b.X + s, y, b.Z})
+	}
+
+	if c.WallMarkers {
+		none := color.RGBA{}
+		ring(ga.Vec3d{-x, b.Y, b.Z}, ga.Vec3d{1, 0, 0}, s, none)
+		ring(ga.Vec3d{+x, b.Y, b.Z}, ga.Vec3d{1, 0, 0}, s, none)
+		ring(ga.Vec3d{b.X, -y, b.Z}, ga.Vec3d{0, 1, 0}, s, none)
+		if !c.Shadow {
+			ring(ga.Vec3d{b.X, y, b.Z}, ga.Vec3d{0, 1, 0}, s, none)
+		}
+	} else {
+		// Mark the wall to the left of the player
+		w := c.seatSize(c.ViewSeat[pln]).X
+		p := c.toView(pln, b)
+		c.drawLine(pln, ga.Vec3d{-w, p.Y - s, p.Z}, ga.Vec3d{-w, p.Y + s, p.Z}, col)
+	}
+
+	c.drawCrossing(pln, ball)
+}
+
+// drawCrossing marks the spot on a paddle plane a ball is going to cross.
+func (c *Game) drawCrossing(pln int, b *Ball) {
+	if !c.CrossingHint {
+		return
+	}
+	if !b.Crosses {
+		return
+	}
+	p, seat := b.Crossing, b.CrossSeat
+
+	s := c.BallSize
+	center := c.toView(pln, p)
+	plane := c.viewPlane(pln, c.seatToWorld(seat, ga.Vec3d{0, 0, 1}))
+	col := c.seatColor(pln, seat, seat != c.ViewSeat[pln])
+
+	c.drawRing(pln, plane, center, s*2, col, color.RGBA{col.R, col.G, col.B, SHADOW_ALPHA})
+	for _, d := range []ga.Vec3d{{s * 3, 0, 0}, {0, s * 3, 0}} {
+		d = c.toView(pln, c.seatToWorld(seat, d))
+		c.drawLine(pln, vec3.Sub(center, d), vec3.Add(center, d), col)
+	}
+}
+
+// predictCrossings works out where every ball is going to cross a
+// paddle plane, once a tick rather than every time it is drawn.
+func (c *Game) predictCrossings() {
+	for i := range c.Balls {
+		b := &c.Balls[i]
+		b.Crosses = false
+		if c.CrossingHint {
+			b.Crossing, b.CrossSeat, b.Crosses = c.predictCrossing(b)
+		}
+	}
+}
+
+// predictCrossing follows a ball forward the same way moveBall does,
+// bouncing off the walls and the net, until it reaches the plane of a
+// paddle. It returns where it crosses and whose paddle plane it is.
+func (c *Game) predictCrossing(ball *Ball) (ga.Vec3d, int, bool) {
+	b := Ball{Pos: ball.Pos, Vel: ball.Vel, Spin: ball.Spin}
+	p := &b.Pos
+	v := &b.Vel
+	s := c.BallSize
+	pace := c.ballPace()
+	for i := 0; i < PREDICT_TICKS; i++ {
+		c.spinBall(&b)
+		c.airBall(&b)
+		p.X += v.X * pace
+		p.Y += v.Y * pace
+		c.collide(p, v, &b.Spin, nil)
+
+		*v = vec3.Add(*v, c.pull(*p))
+
+		p.Z += v.Z * pace
+		for seat := 0; seat < MAX_SEATS; seat++ {
+			if c.front(seat) {
+				continue
+			}
+			a := c.seatSize(seat)
+			q := c.worldToSeat(seat, *p)
+			if q.Z >= -a.Z+s {
+				continue
+			}
+			if c.guarded(seat) && (seat >= 2 || c.inGoal(seat, p.X, p.Y)) {
+				return c.seatToWorld(seat, ga.Vec3d{q.X, q.Y, -a.Z}), seat, true
+			}
+			if seat < 2 {
+				c.bounceEnd(&b, seat)
+			}
+		}
+
+		if c.NetHeight != 0 && p.Z >= -s && p.Z <= s && p.Y >= c.NetHeight-s {
+			v.Z = -v.Z
+			p.Z += v.Z
+		}
+	}
+	return ga.Vec3d{}, 0, false
+}
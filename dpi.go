@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/qeedquan/go-media/image/ttf"
+	"github.com/qeedquan/go-media/sdl"
+	"github.com/qeedquan/go-media/sdl/sdlttf"
+)
+
+const (
+	// Size of the HUD font at a scale of 1
+	FONT_SIZE = 16
+
+	// What the OS considers a normal density display
+	BASE_DPI = 96
+)
+
+// updateScale recomputes the size of the window in pixels and how much to
+// scale the HUD by, it is called whenever the window changes size or display.
+func (c *Game) updateScale() {
+	ow, oh, err := c.Renderer.OutputSize()
+	ck(err)
+	ww, _ := c.Window.Size()
+
+	c.Width, c.Height = ow, oh
+	c.PixelRatio = 1
+	if ww > 0 {
+		c.PixelRatio = float64(ow) / float64(ww)
+	}
+
+	display, err := c.Window.DisplayIndex()
+	if err == nil {
+		c.Display = display
+	}
+
+	c.UIScale = c.Config.UIScale
+	if c.UIScale <= 0 {
+		c.UIScale = c.PixelRatio
+
+		// Some platforms do not report high density displays through
+		// the pixel ratio, only through the DPI
+		ddpi, _, _, err := sdl.GetDisplayDPI(c.Display)
+		if err == nil && ddpi > 0 {
+			c.UIScale = math.Max(c.UIScale, float64(ddpi)/BASE_DPI)
+		}
+
+		// Avoid uneven looking fractional scales
+		c.UIScale = math.Max(1, math.Round(c.UIScale*4)/4)
+	}
+	c.LineWidth = int(math.Max(1, math.Round(c.UIScale)))
+
+	c.openFont()
+	c.layout()
+}
+
+// openFont opens the HUD font at the size matching the scale,
+// the font is only reopened if the size changed.
+func (c *Game) openFont() {
+	size := int(math.Round(FONT_SIZE * c.UIScale))
+	if c.Font != nil && size == c.FontSize {
+		return
+	}
+
+	font, err := sdlttf.OpenFontMem(ttf.VGA437["default"], size)
+	ck(err)
+
+	if c.Font != nil {
+		c.Font.Close()
+	}
+	c.Font = font
+	c.FontSize = size
+	c.FontHeight = font.Height()
+	c.buildAtlas()
+}
+
+// px scales a size given in points at normal density to pixels.
+func (c *Game) px(n int) int {
+	s := c.UIScale
+	if s == 0 {
+		s = 1
+	}
+	return int(math.Round(float64(n) * s))
+}
+
+// toPixels converts a mouse position in window coordinates to pixels.
+func (c *Game) toPixels(x, y int32) image.Point {
+	r := c.PixelRatio
+	if r == 0 {
+		r = 1
+	}
+	return image.Pt(int(float64(x)*r), int(float64(y)*r))
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+	"github.com/qeedquan/go-media/sdl"
+	"github.com/qeedquan/go-media/sdl/sdlimage/sdlcolor"
+)
+
+const (
+	// How far the arrow keys move things without snapping
+	EDIT_STEP = 1
+
+	// How close in pixels a click has to be to pick something
+	PICK_RADIUS = 20
+
+	// Size of new slabs and bumpers
+	NEW_SLAB   = 20
+	NEW_BUMPER = 12
+
+	// Most edits that can be undone
+	MAX_UNDO = 100
+)
+
+var snapSizes = [...]float64{0, 5, 10, 25}
+
+var selectColor = color.RGBA{255, 255, 255, 255}
+
+// The editor edits the arena in place, so the game draws and collides
+// with it as it is edited and it can be test played at any time.
+type Editor struct {
+	Active  bool
+	File    string
+	Snap    int
+	Slab    int
+	Bumper  int
+	Undo    []ArenaDef
+	Redo    []ArenaDef
+	Message string
+
+	// The view player 1 had before the editor switched to FreeView
+	View int
+
+	// Dragging with the left button moves, with shift it resizes,
+	// the arena from before goes in the undo history if it changed
+	Dragging bool
+	Resizing bool
+	Before   ArenaDef
+}
+
+// clone copies an arena so later edits do not change the copy.
+func (d ArenaDef) clone() ArenaDef {
+	d.Slabs = append([]Slab(nil), d.Slabs...)
+	d.Bumpers = append([]Bumper(nil), d.Bumpers...)
+	d.Wells = append([]Well(nil), d.Wells...)
+	return d
+}
+
+// same reports whether d and o are the same arena.
+func (d ArenaDef) same(o ArenaDef) bool {
+	if d.Name != o.Name || d.Size != o.Size || d.Bounce != o.Bounce || d.Friction != o.Friction || d.Goals != o.Goals ||
+		len(d.Slabs) != len(o.Slabs) || len(d.Bumpers) != len(o.Bumpers) || len(d.Wells) != len(o.Wells) {
+		return false
+	}
+	for i := range d.Slabs {
+		if d.Slabs[i] != o.Slabs[i] {
+			return false
+		}
+	}
+	for i := range d.Bumpers {
+		if d.Bumpers[i] != o.Bumpers[i] {
+			return false
+		}
+	}
+	for i := range d.Wells {
+		if d.Wells[i] != o.Wells[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *ArenaDef) Save(name string) error {
+	buf, err := json.MarshalIndent(d, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(buf, '\n'), 0644)
+}
+
+// openEditor starts editing the current arena, built in arenas are
+// saved to the config directory under their own name.
+func (c *Game) openEditor() {
+	e := &c.Editor
+	e.Active = true
+	e.Dragging = false
+	e.Message = ""
+	e.Slab, e.Bumper = -1, -1
+	file := c.arenaPath(c.Config.ArenaFile)
+	for _, b := range builtinArenas {
+		if b.Name == c.Config.ArenaFile {
+			file = configPath(filepath.Join("arenas", b.Name+".json"))
+		}
+	}
+	if file != e.File {
+		// Different arena, the old undo history does not apply to it
+		e.File = file
+		e.Undo, e.Redo = nil, nil
+	}
+
+	e.View = c.View[0]
+	c.View[0] = 3
+	c.Menu.Active = false
+	c.Pause = false
+}
+
+func (c *Game) closeEditor() {
+	e := &c.Editor
+	e.Active = false
+	e.Dragging = false
+	e.Message = ""
+	c.reset()
+	c.View[0] = e.View
+}
+
+// edit remembers the arena before a change so it can be undone.
+func (c *Game) edit() {
+	c.remember(c.ArenaDef.clone())
+}
+
+// remember puts d in the undo history.
+func (c *Game) remember(d ArenaDef) {
+	e := &c.Editor
+	e.Undo = append(e.Undo, d)
+	if len(e.Undo) > MAX_UNDO {
+		e.Undo = e.Undo[1:]
+	}
+	e.Redo = e.Redo[:0]
+}
+
+func (c *Game) undo() {
+	e := &c.Editor
+	if len(e.Undo) == 0 {
+		return
+	}
+	e.Redo = append(e.Redo, c.ArenaDef.clone())
+	c.setArena(e.Undo[len(e.Undo)-1])
+	e.Undo = e.Undo[:len(e.Undo)-1]
+	c.clampSelection()
+}
+
+func (c *Game) redo() {
+	e := &c.Editor
+	if len(e.Redo) == 0 {
+		return
+	}
+	e.Undo = append(e.Undo, c.ArenaDef.clone())
+	c.setArena(e.Redo[len(e.Redo)-1])
+	e.Redo = e.Redo[:len(e.Redo)-1]
+	c.clampSelection()
+}
+
+func (c *Game) clampSelection() {
+	e := &c.Editor
+	if e.Slab >= len(c.ArenaDef.Slabs) {
+		e.Slab = -1
+	}
+	if e.Bumper >= len(c.ArenaDef.Bumpers) {
+		e.Bumper = -1
+	}
+}
+
+// selectNext steps the selection through the slabs and then the bumpers.
+func (c *Game) selectNext(dir int) {
+	e := &c.Editor
+	d := &c.ArenaDef
+	n := len(d.Slabs) + len(d.Bumpers)
+	if n == 0 {
+		return
+	}
+
+	i := -1
+	if e.Slab >= 0 {
+		i = e.Slab
+	} else if e.Bumper >= 0 {
+		i = len(d.Slabs) + e.Bumper
+	}
+	if i < 0 && dir < 0 {
+		i = 0
+	}
+	i = (i + dir + n) % n
+	c.selectIndex(i)
+}
+
+func (c *Game) selectIndex(i int) {
+	e := &c.Editor
+	e.Slab, e.Bumper = -1, -1
+	if i < len(c.ArenaDef.Slabs) {
+		e.Slab = i
+	} else {
+		e.Bumper = i - len(c.ArenaDef.Slabs)
+	}
+}
+
+// snap rounds x to the grid if snapping is on.
+func (c *Game) snap(x float64) float64 {
+	s := snapSizes[c.Editor.Snap]
+	if s == 0 {
+		return x
+	}
+	return math.Round(x/s) * s
+}
+
+// editStep is how far one press of a key moves or resizes something.
+func (c *Game) editStep() float64 {
+	if s := snapSizes[c.Editor.Snap]; s != 0 {
+		return s
+	}
+	return EDIT_STEP
+}
+
+// moveSelected moves what is selected by delta, or resizes it when resize
+// is set, and keeps it inside of the arena.
+func (c *Game) moveSelected(delta ga.Vec3d, resize bool) {
+	e := &c.Editor
+	d := &c.ArenaDef
+	a := c.Arena
+	switch {
+	case e.Slab >= 0:
+		s := &d.Slabs[e.Slab]
+		if resize {
+			s.Min = vec3.Sub(s.Min, delta)
+			s.Max = vec3.Add(s.Max, delta)
+
+			// Never turn a slab inside out
+			mid := vec3.Scale(vec3.Add(s.Min, s.Max), 0.5)
+			s.Min.X, s.Max.X = math.Min(s.Min.X, mid.X-1), math.Max(s.Max.X, mid.X+1)
+			s.Min.Y, s.Max.Y = math.Min(s.Min.Y, mid.Y-1), math.Max(s.Max.Y, mid.Y+1)
+			s.Min.Z, s.Max.Z = math.Min(s.Min.Z, mid.Z-1), math.Max(s.Max.Z, mid.Z+1)
+		} else {
+			s.Min = vec3.Add(s.Min, delta)
+			s.Max = vec3.Add(s.Max, delta)
+		}
+		s.Min = ga.Vec3d{clampAbs(s.Min.X, a.X), clampAbs(s.Min.Y, a.Y), clampAbs(s.Min.Z, a.Z)}
+		s.Max = ga.Vec3d{clampAbs(s.Max.X, a.X), clampAbs(s.Max.Y, a.Y), clampAbs(s.Max.Z, a.Z)}
+
+	case e.Bumper >= 0:
+		b := &d.Bumpers[e.Bumper]
+		if resize {
+			r := delta.X + delta.Y + delta.Z
+			b.Radius = math.Max(1, b.Radius+r)
+		} else {
+			b.Pos = vec3.Add(b.Pos, delta)
+		}
+		b.Pos = ga.Vec3d{clampAbs(b.Pos.X, a.X), clampAbs(b.Pos.Y, a.Y), clampAbs(b.Pos.Z, a.Z)}
+	}
+	c.setArena(*d)
+}
+
+// snapSelected lines what is selected up with the grid.
+func (c *Game) snapSelected() {
+	e := &c.Editor
+	d := &c.ArenaDef
+	switch {
+	case e.Slab >= 0:
+		s := &d.Slabs[e.Slab]
+		s.Min = ga.Vec3d{c.snap(s.Min.X), c.snap(s.Min.Y), c.snap(s.Min.Z)}
+		s.Max = ga.Vec3d{c.snap(s.Max.X), c.snap(s.Max.Y), c.snap(s.Max.Z)}
+		if s.Max.X <= s.Min.X || s.Max.Y <= s.Min.Y || s.Max.Z <= s.Min.Z {
+			s.Max = vec3.Add(s.Min, ga.Vec3d{c.editStep(), c.editStep(), c.editStep()})
+		}
+	case e.Bumper >= 0:
+		b := &d.Bumpers[e.Bumper]
+		b.Pos = ga.Vec3d{c.snap(b.Pos.X), c.snap(b.Pos.Y), c.snap(b.Pos.Z)}
+		b.Radius = math.Max(c.editStep(), c.snap(b.Radius))
+	}
+	c.setArena(*d)
+}
+
+func clampAbs(x, limit float64) float64 {
+	return ga.Clamp(x, -limit, limit)
+}
+
+// viewAxes are the directions in the arena that point right and down
+// on the screen with the FreeView camera of player 1.
+func (c *Game) viewAxes() (ga.Vec3d, ga.Vec3d) {
+	// Undo the rotations of transform, first around X and then around Y
+	cy, sy := c.CosAngle[0].Y, c.SinAngle[0].Y
+	cx, sx := c.CosAngle[0].X, c.SinAngle[0].X
+	inverse := func(v ga.Vec3d) ga.Vec3d {
+		y := v.Y*cy + v.Z*sy
+		z := -v.Y*sy + v.Z*cy
+		x := v.X*cx + z*sx
+		z = -v.X*sx + z*cx
+		return c.seatToWorld(c.ViewSeat[0], ga.Vec3d{x, y, z})
+	}
+	return inverse(ga.Vec3d{1, 0, 0}), inverse(ga.Vec3d{0, 1, 0})
+}
+
+// pick selects whatever is drawn closest to the mouse.
+func (c *Game) pick(x, y int32) {
+	m := c.toPixels(x, y)
+	best := float64(c.px(PICK_RADIUS))
+	found := -1
+
+	d := &c.ArenaDef
+	centers := make([]ga.Vec3d, 0, len(d.Slabs)+len(d.Bumpers))
+	for _, s := range d.Slabs {
+		centers = append(centers, vec3.Scale(vec3.Add(s.Min, s.Max), 0.5))
+	}
+	for _, b := range d.Bumpers {
+		centers = append(centers, b.Pos)
+	}
+	for i, p := range centers {
+		pp := c.transform(0, c.toView(0, p))
+		if pp.Z <= -c.Distance {
+			continue
+		}
+
+		// In stereo it can be picked in the image of either eye
+		for eye := 0; eye < c.eyes(0); eye++ {
+			if r, _, _ := c.eyeView(0, eye); !m.In(r) {
+				continue
+			}
+			s := c.project(0, eye, pp)
+			if dist := math.Hypot(s.X-float64(m.X), s.Y-float64(m.Y)); dist < best {
+				best, found = dist, i
+			}
+		}
+	}
+
+	c.Editor.Slab, c.Editor.Bumper = -1, -1
+	if found >= 0 {
+		c.selectIndex(found)
+	}
+}
+
+func (c *Game) editorEvent(ev interface{}) {
+	e := &c.Editor
+	switch ev := ev.(type) {
+	case sdl.QuitEvent:
+		c.Quit = true
+
+	case sdl.KeyDownEvent:
+		ctrl := ev.Mod&sdl.KMOD_CTRL != 0
+		shift := ev.Mod&sdl.KMOD_SHIFT != 0
+		step := c.editStep()
+		var delta ga.Vec3d
+
+		switch ev.Sym {
+		case sdl.K_ESCAPE:
+			c.closeEditor()
+		case sdl.K_F5, sdl.K_t:
+			// Play the arena as it is, the editor key comes back to it
+			c.closeEditor()
+			e.Message = "Testing"
+		case sdl.K_TAB:
+			if shift {
+				c.selectNext(-1)
+			} else {
+				c.selectNext(1)
+			}
+		case sdl.K_n:
+			c.edit()
+			p := ga.Vec3d{c.snap(0), c.snap(0), c.snap(0)}
+			s := ga.Vec3d{NEW_SLAB, NEW_SLAB, NEW_SLAB}
+			c.ArenaDef.Slabs = append(c.ArenaDef.Slabs, Slab{Min: vec3.Sub(p, s), Max: vec3.Add(p, s)})
+			c.setArena(c.ArenaDef)
+			c.selectIndex(len(c.ArenaDef.Slabs) - 1)
+		case sdl.K_b:
+			c.edit()
+			c.ArenaDef.Bumpers = append(c.ArenaDef.Bumpers, Bumper{Radius: NEW_BUMPER})
+			c.setArena(c.ArenaDef)
+			c.selectIndex(len(c.ArenaDef.Slabs) + len(c.ArenaDef.Bumpers) - 1)
+		case sdl.K_DELETE, sdl.K_BACKSPACE:
+			d := &c.ArenaDef
+			switch {
+			case e.Slab >= 0:
+				c.edit()
+				d.Slabs = append(d.Slabs[:e.Slab], d.Slabs[e.Slab+1:]...)
+			case e.Bumper >= 0:
+				c.edit()
+				d.Bumpers = append(d.Bumpers[:e.Bumper], d.Bumpers[e.Bumper+1:]...)
+			}
+			e.Slab, e.Bumper = -1, -1
+			c.setArena(*d)
+		case sdl.K_g:
+			e.Snap = (e.Snap + 1) % len(snapSizes)
+		case sdl.K_z:
+			if ctrl && shift {
+				c.redo()
+			} else if ctrl {
+				c.undo()
+			}
+		case sdl.K_y:
+			if ctrl {
+				c.redo()
+			}
+		case sdl.K_s:
+			if ctrl {
+				c.saveArena()
+			}
+		case sdl.K_o, sdl.K_l:
+			if ctrl {
+				c.reloadArena()
+			}
+		case sdl.K_LEFT:
+			delta.X = -step
+		case sdl.K_RIGHT:
+			delta.X = step
+		case sdl.K_UP:
+			delta.Z = step
+		case sdl.K_DOWN:
+			delta.Z = -step
+		case sdl.K_PAGEUP:
+			delta.Y = -step
+		case sdl.K_PAGEDOWN:
+			delta.Y = step
+		}
+
+		if delta != (ga.Vec3d{}) && (e.Slab >= 0 || e.Bumper >= 0) {
+			before := c.ArenaDef.clone()
+			c.moveSelected(delta, shift)
+
+			// Holding a key down undoes in one go
+			if ev.Repeat == 0 && !before.same(c.ArenaDef) {
+				c.remember(before)
+			}
+		}
+
+	case sdl.MouseButtonDownEvent:
+		switch int(ev.Button) {
+		case sdl.BUTTON_LEFT:
+			c.pick(ev.X, ev.Y)
+			if e.Slab >= 0 || e.Bumper >= 0 {
+				e.Before = c.ArenaDef.clone()
+				e.Dragging = true
+				e.Resizing = sdl.GetModState()&sdl.KMOD_SHIFT != 0
+			}
+		case sdl.BUTTON_MIDDLE:
+			c.Held[0][ACTION_ORBIT] = true
+		}
+
+	case sdl.MouseButtonUpEvent:
+		switch int(ev.Button) {
+		case sdl.BUTTON_LEFT:
+			if e.Dragging {
+				c.snapSelected()
+				if !e.Before.same(c.ArenaDef) {
+					c.remember(e.Before)
+				}
+			}
+			e.Dragging = false
+		case sdl.BUTTON_MIDDLE:
+			c.Held[0][ACTION_ORBIT] = false
+		}
+
+	case sdl.MouseMotionEvent:
+		if e.Dragging {
+			// Move along the screen at the depth of the middle of the arena
+			right, down := c.viewAxes()
+			m := c.toPixels(ev.Xrel, ev.Yrel)
+			s := c.Distance / (c.Aspect * c.Scale[0])
+			delta := vec3.Add(vec3.Scale(right, float64(m.X)*s), vec3.Scale(down, float64(m.Y)*s))
+			c.moveSelected(delta, e.Resizing)
+		} else if c.Held[0][ACTION_ORBIT] {
+			c.orbit(0, ev.Xrel, ev.Yrel)
+		}
+	}
+}
+
+func (c *Game) saveArena() {
+	e := &c.Editor
+	err := c.ArenaDef.Validate(&c.Config)
+	if err == nil {
+		err = c.ArenaDef.Save(e.File)
+	}
+	if err != nil {
+		e.Message = err.Error()
+		return
+	}
+	c.Config.ArenaFile = e.File
+	e.Message = "Saved " + e.File
+}
+
+func (c *Game) reloadArena() {
+	e := &c.Editor
+	d, err := c.loadArena(e.File, c.Config.Arena)
+	if err == nil {
+		err = d.Validate(&c.Config)
+	}
+	if err != nil {
+		e.Message = err.Error()
+		return
+	}
+	c.edit()
+	c.setArena(d)
+	c.clampSelection()
+	e.Message = "Loaded " + e.File
+}
+
+// editorColor is the color to draw a slab or bumper in,
+// what is selected in the editor stands out.
+func (c *Game) editorColor(slab, bumper int, col color.RGBA) color.RGBA {
+	e := &c.Editor
+	if !e.Active {
+		return col
+	}
+	if (slab >= 0 && slab == e.Slab) || (bumper >= 0 && bumper == e.Bumper) {
+		return selectColor
+	}
+	return col
+}
+
+func (c *Game) drawEditor(pln int) {
+	e := &c.Editor
+	if pln != 0 {
+		return
+	}
+	if !e.Active {
+		// Down at the bottom, out of the way of the scores
+		if e.Message != "" {
+			b := c.Bound[0]
+			y := b.Dy() - c.px(TEXT_MARGIN) - c.FontHeight
+			c.drawTextAligned(0, ALIGN_CENTER, b.Dx()/2, y, c.Colors[0][5], "%s - %s to edit", e.Message, c.Keys[0].Describe(ACTION_EDITOR))
+		}
+		return
+	}
+
+	if s := snapSizes[e.Snap]; s != 0 {
+		c.drawGrid(0, s, c.Colors[0][5])
+	}
+
+	x := c.px(TEXT_MARGIN)
+	fh := c.FontHeight
+	y := fh * 4
+	snap := "Off"
+	if s := snapSizes[e.Snap]; s != 0 {
+		snap = fmt.Sprint(s)
+	}
+	selected := "Nothing"
+	if e.Slab >= 0 {
+		selected = fmt.Sprintf("Slab %d", e.Slab+1)
+	} else if e.Bumper >= 0 {
+		selected = fmt.Sprintf("Bumper %d", e.Bumper+1)
+	}
+	c.drawText(0, x, y, sdlcolor.White, "ARENA EDITOR  %s\nSnap: %s  Selected: %s", e.File, snap, selected)
+	y += fh * 3
+	c.drawText(0, x, y, c.Colors[0][5], ""+
+		"Click: select  Drag: move  Shift+Drag: resize\n"+
+		"%s drag: orbit  Tab: next\n"+
+		"Arrows/PgUp/PgDn: move  Shift: resize\n"+
+		"N: new slab  B: new bumper  Del: delete\n"+
+		"G: snap  Ctrl+Z/Y: undo/redo\n"+
+		"Ctrl+S: save  Ctrl+O: load  T: test  Esc: done",
+		c.Keys[0].Describe(ACTION_ORBIT))
+	if e.Message != "" {
+		c.drawText(0, x, y+fh*7, c.Colors[0][2], "%s", e.Message)
+	}
+}
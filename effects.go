@@ -0,0 +1,158 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+const (
+	EFFECT_IMPACT = iota
+	EFFECT_FLASH
+)
+
+const (
+	// Ball speed drawn with the hottest trail color
+	TRAIL_FAST_SPEED = 12
+
+	// How many times the size of the ball the rings grow to
+	IMPACT_GROWTH = 4
+	FLASH_GROWTH  = 3
+)
+
+// Trail colors from a slow ball to a fast one
+var (
+	trailSlow = color.RGBA{0, 128, 255, 255}
+	trailFast = color.RGBA{255, 64, 0, 255}
+)
+
+// A TrailPoint is where the ball was on an earlier tick.
+type TrailPoint struct {
+	Pos   ga.Vec3d
+	Speed float64
+}
+
+// An Effect is a short lived ring drawn where the ball hit a paddle or a wall.
+type Effect struct {
+	Kind   int
+	Side   int
+	Pos    ga.Vec3d
+	Normal ga.Vec3d
+	Time   int
+	Total  int
+}
+
+// moveEffects records the ball trails and ages the effects.
+func (c *Game) moveEffects() {
+	for i := range c.Balls {
+		b := &c.Balls[i]
+		if c.TrailLength <= 0 {
+			b.Trail = b.Trail[:0]
+			continue
+		}
+		if len(b.Trail) >= c.TrailLength {
+			n := copy(b.Trail, b.Trail[len(b.Trail)-c.TrailLength+1:])
+			b.Trail = b.Trail[:n]
+		}
+		b.Trail = append(b.Trail, TrailPoint{b.Pos, vec3.Len(b.Vel)})
+	}
+
+	fx := c.Effects[:0]
+	for _, e := range c.Effects {
+		if e.Time--; e.Time > 0 {
+			fx = append(fx, e)
+		}
+	}
+	c.Effects = fx
+}
+
+// addImpact starts a ring on the paddle plane of a side where the ball hit.
+func (c *Game) addImpact(side int, b *Ball) {
+	if c.ImpactTime <= 0 {
+		return
+	}
+	c.Effects = append(c.Effects, Effect{
+		Kind:  EFFECT_IMPACT,
+		Side:  side,
+		Pos:   b.Pos,
+		Time:  c.ImpactTime,
+		Total: c.ImpactTime,
+	})
+}
+
+// hitSurface throws sparks off a surface the ball bounced off
+// and starts a flash at the point the ball touched it.
+func (c *Game) hitSurface(p, n ga.Vec3d) {
+	c.playSound("wall")
+	c.addSparks(p, n)
+
+	if c.FlashTime <= 0 {
+		return
+	}
+	c.Effects = append(c.Effects, Effect{
+		Kind:   EFFECT_FLASH,
+		Pos:    p,
+		Normal: n,
+		Time:   c.FlashTime,
+		Total:  c.FlashTime,
+	})
+}
+
+// drawTrail draws lines through the last positions of each ball,
+// fading out with age and getting hotter in color with speed.
+func (c *Game) drawTrail(pln int) {
+	if c.ballHidden(pln) {
+		return
+	}
+	for _, ball := range c.Balls {
+		trail := ball.Trail
+		n := len(trail)
+		for i := 1; i < n; i++ {
+			a := &trail[i-1]
+			b := &trail[i]
+			t := ga.Clamp(b.Speed/TRAIL_FAST_SPEED, 0, 1)
+			col := lerpColor(t, trailSlow, trailFast)
+			col = shade(col, float64(i)/float64(n))
+			c.drawLine(pln, c.toView(pln, a.Pos), c.toView(pln, b.Pos), col)
+		}
+	}
+}
+
+// drawEffects draws the impact and wall flash rings, they grow
+// and fade out over their lifetime.
+func (c *Game) drawEffects(pln int) {
+	for i := range c.Effects {
+		e := &c.Effects[i]
+		t := 1 - float64(e.Time)/float64(e.Total)
+		fade := 1 - t
+		s := c.BallSize
+
+		switch e.Kind {
+		case EFFECT_IMPACT:
+			// Flat against the paddle plane of the seat that hit it
+			q := c.worldToSeat(e.Side, e.Pos)
+			q.Z = c.paddleZ(e.Side)
+			p := c.toView(pln, c.seatToWorld(e.Side, q))
+			plane := c.viewPlane(pln, c.seatToWorld(e.Side, ga.Vec3d{0, 0, 1}))
+			col := c.seatColor(pln, e.Side, e.Side != c.ViewSeat[pln])
+			c.drawRing(pln, plane, p, s+s*IMPACT_GROWTH*t, shade(col, fade), color.RGBA{})
+
+		case EFFECT_FLASH:
+			// Draw the ring flat against the surface
+			plane := c.viewPlane(pln, e.Normal)
+			col := shade(c.Colors[pln][5], fade)
+			fill := color.RGBA{255, 255, 255, uint8(SHADOW_ALPHA * fade)}
+			c.drawRing(pln, plane, c.toView(pln, e.Pos), s+s*FLASH_GROWTH*t, col, fill)
+		}
+	}
+}
+
+func lerpColor(t float64, a, b color.RGBA) color.RGBA {
+	return color.RGBA{
+		uint8(ga.Lerp(t, float64(a.R), float64(b.R))),
+		uint8(ga.Lerp(t, float64(a.G), float64(b.G))),
+		uint8(ga.Lerp(t, float64(a.B), float64(b.B))),
+		uint8(ga.Lerp(t, float64(a.A), float64(b.A))),
+	}
+}
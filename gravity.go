@@ -0,0 +1,184 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec2"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+// Which way gravity pulls
+const (
+	// Down in versus modes and toward the player in handball
+	GRAVITY_CLASSIC = iota
+	// Along the direction in the config
+	GRAVITY_VECTOR
+	// Not at all
+	GRAVITY_ZERO
+	// Along an axis that changes every so often
+	GRAVITY_ROTATING
+	NUM_GRAVITY_MODES
+)
+
+var gravityModeNames = [NUM_GRAVITY_MODES]string{"Classic", "Vector", "Zero-g", "Rotating"}
+
+// The directions rotating gravity picks from
+var gravityAxes = [...]ga.Vec3d{
+	{1, 0, 0}, {-1, 0, 0},
+	{0, 1, 0}, {0, -1, 0},
+	{0, 0, 1}, {0, 0, -1},
+}
+
+const (
+	// Length of the gravity arrow in the HUD
+	GRAVITY_ARROW = 20
+)
+
+var (
+	wellColor     = color.RGBA{0, 200, 255, 255}
+	repellerColor = color.RGBA{255, 100, 0, 255}
+)
+
+// A Well pulls the ball toward it, or pushes it away with a negative
+// Strength, harder the closer the ball is. It has no pull past Radius.
+type Well struct {
+	Pos      ga.Vec3d
+	Radius   float64
+	Strength float64
+}
+
+// gravity is the pull on the ball for the gravity mode, flipped
+// while any player has the gravity flip. Classic gravity is off in
+// four player mode so the ball does not favor any of the goals.
+func (c *Game) gravity() ga.Vec3d {
+	var g ga.Vec3d
+	switch c.GravityMode {
+	case GRAVITY_CLASSIC:
+		switch c.Mode {
+		case FOUR_PLAYERS:
+		case HANDBALL:
+			g = ga.Vec3d{0, 0, -c.Gravity}
+		default:
+			g = ga.Vec3d{0, c.Gravity, 0}
+		}
+	case GRAVITY_VECTOR, GRAVITY_ROTATING:
+		g = vec3.Scale(c.GravityDir, c.Gravity)
+	}
+
+	for seat := 0; seat < MAX_SEATS; seat++ {
+		if c.power(POWER_GRAVITY_FLIP, seat) != 0 {
+			return vec3.Scale(g, -1)
+		}
+	}
+	return g
+}
+
+// pull is how much gravity and the wells of the arena speed up a ball at p.
+func (c *Game) pull(p ga.Vec3d) ga.Vec3d {
+	g := c.gravity()
+	for _, w := range c.ArenaDef.Wells {
+		d := vec3.Sub(w.Pos, p)
+		l := vec3.Len(d)
+		if l == 0 || l >= w.Radius {
+			continue
+		}
+		g = vec3.Add(g, vec3.Scale(d, w.Strength*(1-l/w.Radius)/l))
+	}
+	return g
+}
+
+// setGravity points gravity along the direction in the config.
+func (c *Game) setGravity() {
+	c.GravityDir = ga.Vec3d{0, 1, 0}
+	if d := c.Config.GravityDir; d != (ga.Vec3d{}) {
+		c.GravityDir = vec3.Normalize(d)
+	}
+	c.GravityTimer = 0
+}
+
+// rotateGravity turns rotating gravity to another axis every period.
+func (c *Game) rotateGravity() {
+	if c.GravityMode != GRAVITY_ROTATING {
+		return
+	}
+	if c.GravityTimer++; float64(c.GravityTimer) < c.GravityPeriod*c.Config.TickRate {
+		return
+	}
+	c.GravityTimer = 0
+
+	for {
+		d := gravityAxes[rand.Intn(len(gravityAxes))]
+		if d != c.GravityDir {
+			c.GravityDir = d
+			break
+		}
+	}
+	c.playSound("wall")
+}
+
+// drawGravity shows an arrow at the top of the view pointing the way
+// gravity pulls as seen from the camera, or a box if it pulls straight
+// toward or away from it.
+func (c *Game) drawGravity(pln int) {
+	b := c.Bound[pln]
+	fh := c.FontHeight
+	col := c.Colors[pln][5]
+	switch c.GravityMode {
+	case GRAVITY_CLASSIC:
+		return
+	case GRAVITY_ZERO:
+		c.drawTextAligned(pln, ALIGN_CENTER, b.Dx()/2, fh/2, col, "Zero-g")
+		return
+	}
+	g := c.gravity()
+	if vec3.Len(g) == 0 {
+		return
+	}
+
+	center := ga.Vec2d{float64(b.Min.X + b.Dx()/2), float64(b.Min.Y + fh*3)}
+	c.drawTextAligned(pln, ALIGN_CENTER, b.Dx()/2, fh/2, col, "Gravity")
+
+	// Project a step along gravity from the middle of the arena
+	p0 := c.project(pln, 0, c.transform(pln, c.toView(pln, ga.Vec3d{})))
+	p1 := c.project(pln, 0, c.transform(pln, c.toView(pln, vec3.Scale(vec3.Normalize(g), c.BallSize))))
+	d := vec2.Sub(p1, p0)
+	l := vec2.Len(d)
+	r := float64(c.px(GRAVITY_ARROW))
+	if l < 1 {
+		s := r / 3
+		c.queueLine(col, ga.Vec2d{center.X - s, center.Y - s}, ga.Vec2d{center.X + s, center.Y - s})
+		c.queueLine(col, ga.Vec2d{center.X + s, center.Y - s}, ga.Vec2d{center.X + s, center.Y + s})
+		c.queueLine(col, ga.Vec2d{center.X + s, center.Y + s}, ga.Vec2d{center.X - s, center.Y + s})
+		c.queueLine(col, ga.Vec2d{center.X - s, center.Y + s}, ga.Vec2d{center.X - s, center.Y - s})
+		return
+	}
+
+	d = vec2.Scale(d, r/l)
+	tail := vec2.Sub(center, vec2.Scale(d, 0.5))
+	tip := vec2.Add(center, vec2.Scale(d, 0.5))
+	c.queueLine(col, tail, tip)
+	for _, a := range [...]float64{math.Pi * 3 / 4, -math.Pi * 3 / 4} {
+		sin, cos := math.Sincos(a)
+		h := ga.Vec2d{(d.X*cos - d.Y*sin) / 3, (d.X*sin + d.Y*cos) / 3}
+		c.queueLine(col, tip, vec2.Add(tip, h))
+	}
+}
+
+// drawWells draws the wells of the arena as rings in three planes.
+func (c *Game) drawWells(pln int) {
+	none := color.RGBA{}
+	for _, w := range c.ArenaDef.Wells {
+		col := wellColor
+		if w.Strength < 0 {
+			col = repellerColor
+		}
+		p := c.toView(pln, w.Pos)
+		s := c.BallSize
+		c.drawRing(pln, PLANE_XY, p, s, col, none)
+		c.drawRing(pln, PLANE_XZ, p, s, col, none)
+		c.drawRing(pln, PLANE_YZ, p, s, col, none)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/sdl"
+)
+
+const (
+	LAYOUT_SIDE_BY_SIDE = iota
+	LAYOUT_TOP_BOTTOM
+	LAYOUT_PICTURE_IN_PICTURE
+)
+
+var layoutNames = [...]string{"Side by side", "Top/Bottom", "Picture in picture"}
+
+const (
+	// Margin between the edge of a viewport and the HUD text
+	TEXT_MARGIN = 10
+
+	// Size of the picture in picture inset relative to the window
+	PIP_FRACTION = 3
+)
+
+// windowSize is the size the window should have for the current mode,
+// each player gets a viewport of the configured size.
+func (c *Game) windowSize() (int, int) {
+	w, h := c.Config.Width, c.Config.Height
+	if c.Viewports == 2 {
+		switch c.Layout {
+		case LAYOUT_SIDE_BY_SIDE:
+			w *= 2
+		case LAYOUT_TOP_BOTTOM:
+			h *= 2
+		}
+	}
+	return w, h
+}
+
+// layout splits the window into a viewport for each player
+// and computes how much to scale the arena to fit in each one.
+func (c *Game) layout() {
+	w, h := c.Width, c.Height
+	c.Bound[0] = image.Rect(0, 0, w, h)
+	if c.Viewports == 2 {
+		switch c.Layout {
+		case LAYOUT_SIDE_BY_SIDE:
+			c.Bound[0] = image.Rect(0, 0, w/2, h)
+			c.Bound[1] = image.Rect(w/2, 0, w, h)
+		case LAYOUT_TOP_BOTTOM:
+			c.Bound[0] = image.Rect(0, 0, w, h/2)
+			c.Bound[1] = image.Rect(0, h/2, w, h)
+		case LAYOUT_PICTURE_IN_PICTURE:
+			pw, ph := w/PIP_FRACTION, h/PIP_FRACTION
+			m := c.px(TEXT_MARGIN)
+			c.Bound[1] = image.Rect(w-pw-m, h-ph-m, w-m, h-m)
+		}
+	}
+
+	// Scale uniformly so the arena keeps its aspect ratio
+	for i := range c.Bound {
+		sx := float64(c.Bound[i].Dx()) / float64(c.Config.Width)
+		sy := float64(c.Bound[i].Dy()) / float64(c.Config.Height)
+		c.Scale[i] = sx
+		if sy < sx {
+			c.Scale[i] = sy
+		}
+	}
+}
+
+// resizeWindow sets the window to the default size for the mode.
+func (c *Game) resizeWindow() {
+	c.Width, c.Height = c.windowSize()
+	c.layout()
+	if c.Window == nil {
+		return
+	}
+
+	if !c.Fullscreen {
+		c.Window.SetSize(c.Width, c.Height)
+	}
+	c.updateScale()
+}
+
+func (c *Game) setLayout(layout int) {
+	c.Config.Layout = layout
+	c.Layout = layout
+	c.resizeWindow()
+}
+
+func (c *Game) windowEvent(ev sdl.WindowEvent) {
+	switch ev.Event {
+	case sdl.WINDOWEVENT_SIZE_CHANGED:
+		if ev.Data1 <= 0 || ev.Data2 <= 0 {
+			break
+		}
+		c.updateScale()
+	case sdl.WINDOWEVENT_MOVED, sdl.WINDOWEVENT_DISPLAY_CHANGED:
+		// The new display might have a different density
+		display, err := c.Window.DisplayIndex()
+		if err == nil && display != c.Display {
+			c.updateScale()
+		}
+	}
+}
+
+// mouse returns the position of the mouse in the window in pixels.
+func (c *Game) mouse() image.Point {
+	mx, my, _ := sdl.GetMouseState()
+	return c.toPixels(int32(mx), int32(my))
+}
+
+// beginViewport restricts drawing to the viewport of a player.
+func (c *Game) beginViewport(pln int) {
+	re := c.Renderer
+	b := c.Bound[pln]
+	r := sdl.Rect{int32(b.Min.X), int32(b.Min.Y), int32(b.Dx()), int32(b.Dy())}
+	re.SetClipRect(&r)
+
+	// The inset is drawn over the other player's view
+	if c.Viewports == 2 && c.Layout == LAYOUT_PICTURE_IN_PICTURE && pln == 1 {
+		re.SetDrawColor(color.RGBA{0, 0, 0, 255})
+		re.FillRect(&r)
+	}
+}
+
+func (c *Game) endViewport(pln int) {
+	re := c.Renderer
+	c.flushLines()
+	c.flushText()
+	re.SetClipRect(nil)
+
+	if c.Viewports != 2 || pln != 1 {
+		return
+	}
+
+	// Separate the views
+	b := c.Bound[pln]
+	col := c.Colors[pln][5]
+	p0 := ga.Vec2d{float64(b.Min.X), float64(b.Min.Y)}
+	p1 := ga.Vec2d{float64(b.Max.X), float64(b.Min.Y)}
+	p2 := ga.Vec2d{float64(b.Max.X), float64(b.Max.Y)}
+	p3 := ga.Vec2d{float64(b.Min.X), float64(b.Max.Y)}
+	switch c.Layout {
+	case LAYOUT_SIDE_BY_SIDE:
+		c.queueLine(col, p0, p3)
+	case LAYOUT_TOP_BOTTOM:
+		c.queueLine(col, p0, p1)
+	case LAYOUT_PICTURE_IN_PICTURE:
+		c.queueLine(col, p0, p1)
+		c.queueLine(col, p1, p2)
+		c.queueLine(col, p2, p3)
+		c.queueLine(col, p3, p0)
+	}
+	c.flushLines()
+}
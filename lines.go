@@ -0,0 +1,218 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/sdl"
+)
+
+// A LineSet is all the segments queued for one render pass, grouped by color,
+// along with the depth sorted geometry of solid mode drawn underneath them.
+type LineSet struct {
+	Batches  []LineBatch
+	Index    map[color.RGBA]int
+	Vertices []sdl.Vertex
+	Indices  []int32
+}
+
+// A LineBatch is all the segments of one color drawn this frame.
+type LineBatch struct {
+	Color    color.RGBA
+	Segments []Segment
+}
+
+type Segment struct {
+	A, B ga.Vec2d
+}
+
+// queueLine adds a segment in screen space to the batch of its color,
+// nothing is drawn until the batches are flushed.
+func (c *Game) queueLine(col color.RGBA, a, b ga.Vec2d) {
+	c.Lines[0].queue(col, a, b)
+}
+
+func (l *LineSet) queue(col color.RGBA, a, b ga.Vec2d) {
+	if l.Index == nil {
+		l.Index = make(map[color.RGBA]int)
+	}
+
+	i, found := l.Index[col]
+	if !found {
+		i = len(l.Batches)
+		if i < cap(l.Batches) {
+			l.Batches = l.Batches[:i+1]
+			l.Batches[i].Color = col
+			l.Batches[i].Segments = l.Batches[i].Segments[:0]
+		} else {
+			l.Batches = append(l.Batches, LineBatch{Color: col})
+		}
+		l.Index[col] = i
+	}
+	b0 := &l.Batches[i]
+	b0.Segments = append(b0.Segments, Segment{a, b})
+}
+
+func (l *LineSet) empty() bool {
+	return len(l.Batches) == 0 && len(l.Indices) == 0
+}
+
+func (l *LineSet) reset() {
+	l.Batches = l.Batches[:0]
+	l.Vertices = l.Vertices[:0]
+	l.Indices = l.Indices[:0]
+	for k := range l.Index {
+		delete(l.Index, k)
+	}
+}
+
+// flushLines draws every queued segment, thin lines go through
+// DrawLines one color at a time while thick or antialiased lines
+// are turned into quads and drawn in a single geometry call.
+func (c *Game) flushLines() {
+	c.drawLineSet(&c.Lines[0])
+	if !c.Lines[1].empty() {
+		c.flushInterlaced()
+	}
+}
+
+func (c *Game) drawLineSet(l *LineSet) {
+	if l.empty() {
+		return
+	}
+
+	if len(l.Indices) > 0 {
+		c.Renderer.SetDrawBlendMode(c.polyBlend())
+		ek(c.Renderer.RenderGeometry(nil, l.Vertices, l.Indices))
+	}
+
+	width := c.lineWidth()
+	switch {
+	case len(l.Batches) == 0:
+	case width <= 1 && !c.Antialias:
+		c.drawThinLines(l)
+	default:
+		c.drawQuadLines(l, width)
+	}
+	l.reset()
+}
+
+func (c *Game) lineWidth() float64 {
+	if c.Config.LineWidth > 0 {
+		return c.Config.LineWidth * c.UIScale
+	}
+	return float64(c.LineWidth)
+}
+
+func (c *Game) drawThinLines(l *LineSet) {
+	re := c.Renderer
+	re.SetDrawBlendMode(c.LineBlend)
+	for _, b := range l.Batches {
+		re.SetDrawColor(b.Color)
+
+		// Segments that continue where the last one ended are joined
+		// into one polyline, like the edges of the arena walls
+		pts := c.Points[:0]
+		for _, s := range b.Segments {
+			p0 := sdl.Point{int32(s.A.X), int32(s.A.Y)}
+			p1 := sdl.Point{int32(s.B.X), int32(s.B.Y)}
+			if n := len(pts); n > 0 && pts[n-1] == p0 {
+				pts = append(pts, p1)
+				continue
+			}
+			if len(pts) > 0 {
+				re.DrawLines(pts)
+			}
+			pts = append(pts[:0], p0, p1)
+		}
+		if len(pts) > 0 {
+			re.DrawLines(pts)
+		}
+		c.Points = pts
+	}
+}
+
+func (c *Game) drawQuadLines(l *LineSet, width float64) {
+	vs := c.Vertices[:0]
+	is := c.Indices[:0]
+	for _, b := range l.Batches {
+		for _, s := range b.Segments {
+			vs, is = c.appendLineQuads(vs, is, b.Color, s.A, s.B, width)
+		}
+	}
+
+	re := c.Renderer
+	re.SetDrawBlendMode(c.polyBlend())
+	ek(re.RenderGeometry(nil, vs, is))
+
+	c.Vertices = vs
+	c.Indices = is
+}
+
+// appendLineQuads adds the triangles of a thick line from a to b.
+func (c *Game) appendLineQuads(vs []sdl.Vertex, is []int32, col color.RGBA, a, b ga.Vec2d, width float64) ([]sdl.Vertex, []int32) {
+	half := width / 2
+	feather := 0.0
+	if c.Antialias {
+		feather = 1
+	}
+
+	solid := sdl.Color{col.R, col.G, col.B, col.A}
+	faded := sdl.Color{col.R, col.G, col.B, 0}
+
+	dx, dy := b.X-a.X, b.Y-a.Y
+	n := math.Hypot(dx, dy)
+	if n == 0 {
+		dx, dy, n = 1, 0, 1
+	}
+	dx, dy = dx/n, dy/n
+
+	// Extend the ends so corners of joined lines close up
+	p0 := ga.Vec2d{a.X - dx*half, a.Y - dy*half}
+	p1 := ga.Vec2d{b.X + dx*half, b.Y + dy*half}
+	nx, ny := -dy, dx
+
+	// Each end has an outer and inner vertex on both sides,
+	// the outer ones fade to transparent for antialiasing
+	o := int32(len(vs))
+	for _, p := range [2]ga.Vec2d{p0, p1} {
+		for _, side := range [...]struct {
+			Dist  float64
+			Color sdl.Color
+		}{
+			{-half - feather, faded},
+			{-half, solid},
+			{half, solid},
+			{half + feather, faded},
+		} {
+			vs = append(vs, sdl.Vertex{
+				Position: sdl.FPoint{float32(p.X + nx*side.Dist), float32(p.Y + ny*side.Dist)},
+				Color:    side.Color,
+			})
+		}
+	}
+
+	// Three quads across the line: feather, core, feather
+	first := 1
+	last := 2
+	if c.Antialias {
+		first, last = 0, 3
+	}
+	for k := int32(first); k < int32(last); k++ {
+		is = append(is,
+			o+k, o+k+1, o+4+k,
+			o+k+1, o+4+k+1, o+4+k,
+		)
+	}
+	return vs, is
+}
+
+// polyBlend is the blend mode for anything drawn as triangles,
+// it keeps the additive blending of the anaglyph modes.
+func (c *Game) polyBlend() sdl.BlendMode {
+	if c.LineBlend == sdl.BLENDMODE_ADD {
+		return sdl.BLENDMODE_ADD
+	}
+	return sdl.BLENDMODE_BLEND
+}
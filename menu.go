@@ -0,0 +1,436 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/qeedquan/go-media/sdl"
+	"github.com/qeedquan/go-media/sdl/sdlimage/sdlcolor"
+)
+
+const (
+	MENU_PLAY = iota
+	MENU_MODE
+	MENU_LAYOUT
+	MENU_ARENA
+	MENU_EDITOR
+	MENU_DIFFICULTY
+	MENU_GRAVITY
+	MENU_GRAVITY_MODE
+	MENU_DRILL
+	MENU_CHALLENGE
+	MENU_VARIANT
+	MENU_PHYSICS
+	MENU_PADDLE
+	MENU_NET
+	MENU_OBSTACLES
+	MENU_BALLS
+	MENU_POWERUPS
+	MENU_SHADOW
+	MENU_WALL_MARKERS
+	MENU_CROSSING
+	MENU_SOUND
+	MENU_FULLSCREEN
+	MENU_CONTROLS
+	MENU_QUIT
+	NUM_MENU
+)
+
+var modeNames = [...]string{"Handball", "One Player", "Two Players", "Four Players", "Doubles", "Practice"}
+
+var difficulties = [...]struct {
+	Name  string
+	Speed float64
+}{
+	{"Easy", 3},
+	{"Normal", 5},
+	{"Hard", 8},
+	{"Insane", 12},
+}
+
+var netSizes = [...]float64{0, 25, 50, 75}
+
+// Most obstacles the menu steps through, more can be set in the config
+const MAX_OBSTACLES = 4
+
+const MENU_MARGIN = 50
+
+type Menu struct {
+	Active bool
+	Title  bool
+	Cursor int
+}
+
+func (c *Game) openMenu() {
+	c.Menu.Active = true
+	c.Menu.Cursor = MENU_PLAY
+}
+
+func (c *Game) menuText(item int) string {
+	f := &c.Config
+	switch item {
+	case MENU_PLAY:
+		if c.Menu.Title {
+			return "Start"
+		}
+		return "Resume"
+	case MENU_MODE:
+		return "Mode:       " + modeNames[c.Mode]
+	case MENU_LAYOUT:
+		return "Layout:     " + layoutNames[c.Layout]
+	case MENU_ARENA:
+		return "Arena:      " + c.ArenaDef.Name
+	case MENU_DIFFICULTY:
+		name := "Custom"
+		for _, d := range difficulties {
+			if d.Speed == c.ComputerSpeed {
+				name = d.Name
+			}
+		}
+		return "Difficulty: " + name
+	case MENU_GRAVITY:
+		return fmt.Sprintf("Gravity:    %.2f", c.Gravity)
+	case MENU_GRAVITY_MODE:
+		return "Pull:       " + gravityModeNames[c.GravityMode]
+	case MENU_DRILL:
+		return "Drill:      " + drillNames[c.Drill]
+	case MENU_CHALLENGE:
+		return "Challenge:  " + challengeNames[c.Challenge]
+	case MENU_VARIANT:
+		return "Variant:    " + variants[c.Variant].Name
+	case MENU_PHYSICS:
+		return "Physics:    " + physicsNames[c.Physics]
+	case MENU_PADDLE:
+		return "Paddle:     " + paddleModelNames[c.PaddleModel]
+	case MENU_NET:
+		if c.Net == 0 {
+			return "Net:        Off"
+		}
+		return fmt.Sprintf("Net:        %.0f", c.Net)
+	case MENU_OBSTACLES:
+		if c.ObstacleCount == 0 {
+			return "Obstacles:  Off"
+		}
+		return fmt.Sprintf("Obstacles:  %d %s", c.ObstacleCount, pathNames[c.ObstaclePath])
+	case MENU_BALLS:
+		if c.MultiBall == 1 {
+			return "Balls:      1"
+		}
+		return fmt.Sprintf("Balls:      up to %d", c.MultiBall)
+	case MENU_POWERUPS:
+		return "Power-ups:  " + onOff(c.PowerUps)
+	case MENU_SHADOW:
+		return "Shadow:     " + onOff(f.Shadow)
+	case MENU_WALL_MARKERS:
+		return "Markers:    " + onOff(f.WallMarkers)
+	case MENU_CROSSING:
+		return "Crossing:   " + onOff(f.CrossingHint)
+	case MENU_SOUND:
+		return "Sound:      " + onOff(f.Sound)
+	case MENU_FULLSCREEN:
+		return "Fullscreen: " + onOff(f.Fullscreen)
+	case MENU_CONTROLS:
+		return "Controls"
+	case MENU_QUIT:
+		return "Quit"
+	}
+	return ""
+}
+
+// menuChange activates an item, dir is -1 or +1 when stepping
+// through the values of an item and 0 when it was selected.
+func (c *Game) menuChange(item, dir int) {
+	f := &c.Config
+	step := dir
+	if step == 0 {
+		step = 1
+	}
+
+	switch item {
+	case MENU_PLAY:
+		if dir == 0 {
+			c.Menu.Active = false
+			c.Menu.Title = false
+		}
+	case MENU_MODE:
+		c.setMode((c.Mode + step + len(modeNames)) % len(modeNames))
+	case MENU_LAYOUT:
+		c.setLayout((c.Layout + step + len(layoutNames)) % len(layoutNames))
+	case MENU_ARENA:
+		i := -1
+		for j, b := range builtinArenas {
+			if b.Name == c.ArenaDef.Name {
+				i = j
+			}
+		}
+		if i < 0 && step < 0 {
+			i = 0
+		}
+		i = (i + step + len(builtinArenas)) % len(builtinArenas)
+		f.ArenaFile = builtinArenas[i].Name
+		arena, err := c.loadArena(f.ArenaFile, f.Arena)
+		ck(err)
+		c.setArena(arena)
+		c.reset()
+	case MENU_EDITOR:
+		if dir == 0 {
+			c.openEditor()
+		}
+	case MENU_DIFFICULTY:
+		i := 0
+		for j, d := range difficulties {
+			if d.Speed == c.ComputerSpeed {
+				i = j
+			}
+		}
+		i = (i + step + len(difficulties)) % len(difficulties)
+		f.ComputerSpeed = difficulties[i].Speed
+		c.ComputerSpeed = f.ComputerSpeed
+	case MENU_GRAVITY:
+		g := c.Gravity + float64(step)*0.25
+		if g > 2 {
+			g = c.MinHandballGravity
+		} else if g < c.MinHandballGravity {
+			g = 2
+		}
+		f.Gravity = g
+		c.Gravity = g
+	case MENU_GRAVITY_MODE:
+		f.GravityMode = (f.GravityMode + step + NUM_GRAVITY_MODES) % NUM_GRAVITY_MODES
+		c.GravityMode = f.GravityMode
+		c.setGravity()
+	case MENU_DRILL:
+		f.Drill = (f.Drill + step + NUM_DRILLS) % NUM_DRILLS
+		c.Drill = f.Drill
+		c.ShotIndex = 0
+	case MENU_CHALLENGE:
+		f.Challenge = (f.Challenge + step + NUM_CHALLENGES) % NUM_CHALLENGES
+		c.setChallenge(f.Challenge)
+	case MENU_VARIANT:
+		f.Variant = (f.Variant + step + NUM_VARIANTS) % NUM_VARIANTS
+		c.setVariant(f.Variant)
+	case MENU_PHYSICS:
+		f.Physics = (f.Physics + step + NUM_PHYSICS) % NUM_PHYSICS
+		c.Physics = f.Physics
+	case MENU_PADDLE:
+		f.PaddleModel = (f.PaddleModel + step + NUM_PADDLE_MODELS) % NUM_PADDLE_MODELS
+		c.PaddleModel = f.PaddleModel
+	case MENU_NET:
+		i := 0
+		for j, n := range netSizes {
+			if n == c.Net {
+				i = j
+			}
+		}
+		i = (i + step + len(netSizes)) % len(netSizes)
+		f.Net = netSizes[i]
+		c.setNet(f.Net)
+	case MENU_OBSTACLES:
+		f.Obstacles = (f.Obstacles + step + MAX_OBSTACLES + 1) % (MAX_OBSTACLES + 1)
+		c.ObstacleCount = f.Obstacles
+		c.placeObstacles()
+	case MENU_BALLS:
+		f.MultiBall = (f.MultiBall-1+step+MAX_BALLS)%MAX_BALLS + 1
+		c.MultiBall = f.MultiBall
+	case MENU_POWERUPS:
+		f.PowerUps = !f.PowerUps
+		c.PowerUps = f.PowerUps
+		if !c.PowerUps {
+			c.clearPowers()
+		}
+	case MENU_SHADOW:
+		f.Shadow = !f.Shadow
+		c.Shadow = f.Shadow
+	case MENU_WALL_MARKERS:
+		f.WallMarkers = !f.WallMarkers
+		c.WallMarkers = f.WallMarkers
+	case MENU_CROSSING:
+		f.CrossingHint = !f.CrossingHint
+		c.CrossingHint = f.CrossingHint
+	case MENU_SOUND:
+		f.Sound = !f.Sound
+		c.Sound = f.Sound
+	case MENU_FULLSCREEN:
+		f.Fullscreen = !f.Fullscreen
+		c.Fullscreen = f.Fullscreen
+		c.setFullscreen()
+	case MENU_CONTROLS:
+		if dir == 0 {
+			c.Rebind = Rebind{Active: true}
+		}
+	case MENU_QUIT:
+		if dir == 0 {
+			c.Quit = true
+		}
+	}
+}
+
+func (c *Game) menuEvent(ev interface{}) {
+	m := &c.Menu
+	switch ev := ev.(type) {
+	case sdl.QuitEvent:
+		c.Quit = true
+	case sdl.KeyDownEvent:
+		switch ev.Sym {
+		case sdl.K_UP:
+			m.Cursor = (m.Cursor + NUM_MENU - 1) % NUM_MENU
+		case sdl.K_DOWN:
+			m.Cursor = (m.Cursor + 1) % NUM_MENU
+		case sdl.K_LEFT:
+			c.menuChange(m.Cursor, -1)
+		case sdl.K_RIGHT:
+			c.menuChange(m.Cursor, 1)
+		case sdl.K_RETURN, sdl.K_SPACE:
+			c.menuChange(m.Cursor, 0)
+		case sdl.K_ESCAPE:
+			if !m.Title {
+				m.Active = false
+			}
+		}
+	case sdl.ControllerButtonDownEvent:
+		switch ev.Button {
+		case sdl.CONTROLLER_BUTTON_DPAD_UP:
+			m.Cursor = (m.Cursor + NUM_MENU - 1) % NUM_MENU
+		case sdl.CONTROLLER_BUTTON_DPAD_DOWN:
+			m.Cursor = (m.Cursor + 1) % NUM_MENU
+		case sdl.CONTROLLER_BUTTON_DPAD_LEFT:
+			c.menuChange(m.Cursor, -1)
+		case sdl.CONTROLLER_BUTTON_DPAD_RIGHT:
+			c.menuChange(m.Cursor, 1)
+		case sdl.CONTROLLER_BUTTON_A:
+			c.menuChange(m.Cursor, 0)
+		case sdl.CONTROLLER_BUTTON_B, sdl.CONTROLLER_BUTTON_START:
+			if !m.Title {
+				m.Active = false
+			}
+		}
+	case sdl.MouseMotionEvent:
+		if item := c.menuItemAt(c.toPixels(ev.X, ev.Y)); item >= 0 {
+			m.Cursor = item
+		}
+	case sdl.MouseButtonDownEvent:
+		item := c.menuItemAt(c.toPixels(ev.X, ev.Y))
+		if item < 0 {
+			break
+		}
+		m.Cursor = item
+		switch ev.Button {
+		case sdl.BUTTON_LEFT:
+			c.menuChange(item, 0)
+		case sdl.BUTTON_RIGHT:
+			c.menuChange(item, -1)
+		}
+	}
+}
+
+// menuRow is the height of a menu item, the items are
+// squeezed together when they do not fit in the window.
+func (c *Game) menuRow() int {
+	fh := c.FontHeight
+	room := (c.Bound[0].Dy() - fh*4) / NUM_MENU
+	switch {
+	case room < fh:
+		return fh
+	case room < fh*2:
+		return room
+	}
+	return fh * 2
+}
+
+// menuRect is where the items of the menu are drawn.
+func (c *Game) menuRect() image.Rectangle {
+	rh := c.menuRow()
+	b := c.Bound[0]
+	m := c.px(MENU_MARGIN)
+	x := b.Min.X + m
+	y := b.Min.Y + (b.Dy()-rh*NUM_MENU)/2 + c.FontHeight
+	return image.Rect(x, y, b.Max.X-m, y+rh*NUM_MENU)
+}
+
+func (c *Game) menuItemAt(p image.Point) int {
+	r := c.menuRect()
+	if !p.In(r) || c.menuRow() == 0 {
+		return -1
+	}
+	return (p.Y - r.Min.Y) / c.menuRow()
+}
+
+func (c *Game) drawMenu(pln int) {
+	if !c.Menu.Active || c.Rebind.Active || pln != 0 {
+		return
+	}
+
+	re := c.Renderer
+	b := c.Bound[0]
+	re.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	re.SetDrawColor(color.RGBA{0, 0, 0, 224})
+	re.FillRect(&sdl.Rect{int32(b.Min.X), int32(b.Min.Y), int32(b.Dx()), int32(b.Dy())})
+
+	fh := c.FontHeight
+	rh := c.menuRow()
+	r := c.menuRect().Sub(b.Min)
+	ty := r.Min.Y - fh*3
+	if ty < 0 {
+		ty = 0
+	}
+	c.drawTextAligned(0, ALIGN_CENTER, b.Dx()/2, ty, sdlcolor.White, "3D PONG")
+	for i := 0; i < NUM_MENU; i++ {
+		col := c.Colors[0][5]
+		prefix := "  "
+		if i == c.Menu.Cursor {
+			col = c.Colors[0][2]
+			prefix = "> "
+		}
+		c.drawText(0, c.px(MENU_MARGIN), r.Min.Y+i*rh, col, "%s%s", prefix, c.menuText(i))
+	}
+}
+
+func onOff(b bool) string {
+	if b {
+		return "On"
+	}
+	return "Off"
+}
+
+// setMode switches the game mode, rebuilding the viewports and window.
+func (c *Game) setMode(mode int) {
+	c.Config.Mode = mode
+	c.Mode = mode
+	c.assignSeats()
+	c.resizeWindow()
+	c.reset()
+}
+
+func (c *Game) setNet(net float64) {
+	c.Net = net
+	c.NetHeight = 0
+	if c.Net != 0 {
+		c.NetHeight = c.Arena.Y - c.Net
+	}
+}
+
+func (c *Game) setFullscreen() {
+	if c.Window == nil {
+		return
+	}
+	flags := 0
+	if c.Fullscreen {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	ek(c.Window.SetFullscreen(flags))
+}
+
+func (c *Game) openControllers() {
+	for i := 0; i < sdl.NumJoysticks(); i++ {
+		if !sdl.IsGameController(i) {
+			continue
+		}
+		gc, err := sdl.GameControllerOpen(i)
+		if err != nil {
+			ek(err)
+			continue
+		}
+		c.Controllers = append(c.Controllers, gc)
+	}
+}
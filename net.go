@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/qeedquan/go-media/math/ga"
+)
+
+// A Link connects a four player game over the network. The host runs the
+// game and sends everyone what happened every tick, the players that
+// joined only send where their paddle is and draw what they are sent.
+type Link struct {
+	Host bool
+
+	// The player that joined wants to serve
+	Serve bool
+
+	conn *net.UDPConn
+	addr *net.UDPAddr
+
+	mu    sync.Mutex
+	peers [MAX_SEATS]*net.UDPAddr
+	in    [MAX_SEATS]PeerMsg
+	snap  *Snapshot
+}
+
+// PeerMsg is what a player that joined sends the host.
+type PeerMsg struct {
+	Seat   int
+	Paddle ga.Vec2d
+	Serve  bool
+}
+
+// Snapshot is what the host sends the players that joined.
+type Snapshot struct {
+	Mode           int
+	Teams          bool
+	Player         [MAX_SEATS]ga.Vec2d
+	Shimmering     [MAX_SEATS]int
+	Score          [MAX_SEATS]int
+	Lives          [MAX_SEATS]int
+	Winner         int
+	Balls          []BallState
+	BallInPlay     bool
+	BallWaitingFor int
+
+	// Everything else the host moves that shows up on screen
+	Floating     []PowerUp
+	Powers       []Power
+	Targets      []Target
+	Obstacles    []Obstacle
+	GravityMode  int
+	GravityDir   ga.Vec3d
+	GravityTimer int
+}
+
+type BallState struct {
+	Pos ga.Vec3d
+	Vel ga.Vec3d
+}
+
+// openLink hosts or joins a game if the config asks for it.
+func (c *Game) openLink() error {
+	f := &c.Config
+	l := &Link{}
+	switch {
+	case f.Host != "":
+		addr, err := net.ResolveUDPAddr("udp", f.Host)
+		if err != nil {
+			return err
+		}
+		l.Host = true
+		l.conn, err = net.ListenUDP("udp", addr)
+		if err != nil {
+			return err
+		}
+
+	case f.Join != "":
+		var err error
+		l.addr, err = net.ResolveUDPAddr("udp", f.Join)
+		if err != nil {
+			return err
+		}
+		l.conn, err = net.ListenUDP("udp", nil)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+
+	c.Link = l
+	go l.read()
+	return nil
+}
+
+// read keeps the last message from every peer until the game picks it up.
+func (l *Link) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := l.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		l.mu.Lock()
+		if l.Host {
+			var m PeerMsg
+			if json.Unmarshal(buf[:n], &m) == nil && 0 <= m.Seat && m.Seat < MAX_SEATS {
+				l.in[m.Seat] = m
+				l.peers[m.Seat] = addr
+			}
+		} else {
+			var s Snapshot
+			if json.Unmarshal(buf[:n], &s) == nil {
+				l.snap = &s
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func (l *Link) send(addr *net.UDPAddr, v interface{}) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	l.conn.WriteToUDP(buf, addr)
+}
+
+// syncLink trades state with the other end of the link, it returns
+// true if the game is run by the host and should not be moved here.
+func (c *Game) syncLink() bool {
+	l := c.Link
+	if l == nil {
+		return false
+	}
+	if l.Host {
+		c.lead()
+		return false
+	}
+	c.follow()
+	return true
+}
+
+// lead moves the paddles of the network players and serves for them,
+// then sends them the state of the game.
+func (c *Game) lead() {
+	l := c.Link
+	l.mu.Lock()
+	in := l.in
+	peers := l.peers
+	for i := range l.in {
+		l.in[i].Serve = false
+	}
+	l.mu.Unlock()
+
+	for seat := 0; seat < c.seats(); seat++ {
+		if c.seatType(seat) != SEAT_REMOTE || peers[seat] == nil {
+			continue
+		}
+		c.Player[seat] = in[seat].Paddle
+		if in[seat].Serve && !c.BallInPlay && c.BallWaitingFor == seat {
+			c.putBallInPlay(seat)
+		}
+	}
+
+	s := Snapshot{
+		Mode:           c.Mode,
+		Teams:          c.Teams,
+		Player:         c.Player,
+		Shimmering:     c.Shimmering,
+		Score:          c.Score,
+		Lives:          c.Lives,
+		Winner:         c.Winner,
+		BallInPlay:     c.BallInPlay,
+		BallWaitingFor: c.BallWaitingFor,
+
+		Floating:     c.Floating,
+		Powers:       c.Powers,
+		Targets:      c.Targets,
+		Obstacles:    c.Obstacles,
+		GravityMode:  c.GravityMode,
+		GravityDir:   c.GravityDir,
+		GravityTimer: c.GravityTimer,
+	}
+	for _, b := range c.Balls {
+		s.Balls = append(s.Balls, BallState{b.Pos, b.Vel})
+	}
+	for seat, addr := range peers {
+		if addr != nil && c.seatType(seat) == SEAT_REMOTE {
+			l.send(addr, &s)
+		}
+	}
+}
+
+// follow sends the host our paddle and shows the last state it sent.
+func (c *Game) follow() {
+	l := c.Link
+	seat := c.ViewSeat[0]
+	l.send(l.addr, &PeerMsg{seat, c.Player[seat], l.Serve})
+
+	l.mu.Lock()
+	s := l.snap
+	l.snap = nil
+	l.mu.Unlock()
+	if s == nil {
+		return
+	}
+
+	if s.Mode != c.Mode || s.Teams != c.Teams {
+		c.Teams = s.Teams
+		c.setMode(s.Mode)
+	}
+	own := c.Player[seat]
+	c.Player = s.Player
+	c.Player[seat] = own
+	c.Shimmering = s.Shimmering
+	c.Score = s.Score
+	c.Lives = s.Lives
+	c.Winner = s.Winner
+	c.BallInPlay = s.BallInPlay
+	c.BallWaitingFor = s.BallWaitingFor
+	c.Floating = s.Floating
+	c.Powers = s.Powers
+	c.Targets = s.Targets
+	c.Obstacles = s.Obstacles
+	c.GravityMode = s.GravityMode
+	c.GravityDir = s.GravityDir
+	c.GravityTimer = s.GravityTimer
+	if c.BallInPlay || c.BallWaitingFor != seat {
+		l.Serve = false
+	}
+
+	// Keep the balls that are still there so their trails stay
+	if len(s.Balls) < len(c.Balls) {
+		c.Balls = c.Balls[:len(s.Balls)]
+	}
+	for i, b := range s.Balls {
+		if i == len(c.Balls) {
+			c.Balls = append(c.Balls, Ball{})
+		}
+		c.Balls[i].Pos = b.Pos
+		c.Balls[i].Vel = b.Vel
+	}
+	c.predictCrossings()
+}
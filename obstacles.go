@@ -0,0 +1,198 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+const (
+	PATH_PERIMETER = iota
+	PATH_BOUNCE
+	PATH_ORBIT
+	NUM_PATHS
+)
+
+var pathNames = [NUM_PATHS]string{"Perimeter", "Bounce", "Orbit"}
+
+var obstacleColor = color.RGBA{255, 128, 0, 255}
+
+// An Obstacle is a block roaming the middle of the arena that the ball
+// bounces off, it grew out of the "crap" object of the original game
+// that walked around the floor but was never drawn.
+type Obstacle struct {
+	Pos   ga.Vec3d
+	Vel   ga.Vec3d
+	Angle float64
+}
+
+// obstacleBounds is how far from the center an obstacle can go,
+// they stay out of the half of the arena next to each paddle.
+func (c *Game) obstacleBounds() ga.Vec3d {
+	s := c.ObstacleSize
+	return ga.Vec3d{
+		math.Max(0, c.Arena.X-s),
+		math.Max(0, c.Arena.Y-s),
+		math.Max(0, c.Arena.Z/2-s),
+	}
+}
+
+// placeObstacles spreads the obstacles out evenly along their path.
+func (c *Game) placeObstacles() {
+	c.Obstacles = c.Obstacles[:0]
+	b := c.obstacleBounds()
+	v := c.ObstacleSpeed
+	for i := 0; i < c.ObstacleCount; i++ {
+		t := float64(i) / float64(c.ObstacleCount)
+		var o Obstacle
+		switch c.ObstaclePath {
+		case PATH_PERIMETER:
+			// Start along the edges of the floor, going around it
+			// the same way the crap object used to
+			perimeter := 4 * (b.X + b.Z)
+			d := t * perimeter
+			switch {
+			case d < 2*b.Z:
+				o.Pos = ga.Vec3d{-b.X, b.Y, -b.Z + d}
+				o.Vel = ga.Vec3d{0, 0, v}
+			case d < 2*b.Z+2*b.X:
+				o.Pos = ga.Vec3d{-b.X + d - 2*b.Z, b.Y, b.Z}
+				o.Vel = ga.Vec3d{v, 0, 0}
+			case d < 4*b.Z+2*b.X:
+				o.Pos = ga.Vec3d{b.X, b.Y, b.Z - (d - 2*b.Z - 2*b.X)}
+				o.Vel = ga.Vec3d{0, 0, -v}
+			default:
+				o.Pos = ga.Vec3d{b.X - (d - 4*b.Z - 2*b.X), b.Y, -b.Z}
+				o.Vel = ga.Vec3d{-v, 0, 0}
+			}
+		case PATH_BOUNCE:
+			o.Pos = ga.Vec3d{
+				(rand.Float64()*2 - 1) * b.X,
+				(rand.Float64()*2 - 1) * b.Y,
+				(rand.Float64()*2 - 1) * b.Z,
+			}
+			o.Vel = vec3.Scale(randomUnit(), v)
+		case PATH_ORBIT:
+			o.Angle = t * 2 * math.Pi
+		}
+		c.Obstacles = append(c.Obstacles, o)
+	}
+	c.moveObstacles()
+}
+
+func (c *Game) moveObstacles() {
+	b := c.obstacleBounds()
+	for i := range c.Obstacles {
+		o := &c.Obstacles[i]
+		switch c.ObstaclePath {
+		case PATH_PERIMETER:
+			v := c.ObstacleSpeed
+			o.Pos = vec3.Add(o.Pos, o.Vel)
+			if o.Pos.X < -b.X {
+				o.Pos.X = -b.X
+				o.Vel = ga.Vec3d{0, 0, v}
+			} else if o.Pos.X > b.X {
+				o.Pos.X = b.X
+				o.Vel = ga.Vec3d{0, 0, -v}
+			}
+
+			if o.Pos.Z < -b.Z {
+				o.Pos.Z = -b.Z
+				o.Vel = ga.Vec3d{-v, 0, 0}
+			} else if o.Pos.Z > b.Z {
+				o.Pos.Z = b.Z
+				o.Vel = ga.Vec3d{v, 0, 0}
+			}
+
+		case PATH_BOUNCE:
+			o.Pos = vec3.Add(o.Pos, o.Vel)
+			bounce(&o.Pos.X, &o.Vel.X, b.X, 1)
+			bounce(&o.Pos.Y, &o.Vel.Y, b.Y, 1)
+			bounce(&o.Pos.Z, &o.Vel.Z, b.Z, 1)
+
+		case PATH_ORBIT:
+			// Circle around the middle of the arena at half height
+			r := math.Min(b.X, b.Z)
+			if r > 0 {
+				o.Angle += c.ObstacleSpeed / r
+			}
+			sin, cos := math.Sincos(o.Angle)
+			p := ga.Vec3d{cos * r, 0, sin * r}
+			o.Vel = vec3.Sub(p, o.Pos)
+			o.Pos = p
+		}
+	}
+}
+
+// collideObstacles bounces the balls off any obstacle they overlap,
+// a ball also picks up the speed of the obstacle.
+func (c *Game) collideObstacles() {
+	r := c.BallSize
+	for i := range c.Balls {
+		b := &c.Balls[i]
+		for j := range c.Obstacles {
+			o := &c.Obstacles[j]
+			s := ga.Vec3d{c.ObstacleSize, c.ObstacleSize, c.ObstacleSize}
+			box := Box{vec3.Sub(o.Pos, s), vec3.Add(o.Pos, s)}
+			n, depth, ok := box.contact(b.Pos, r)
+			if !ok {
+				continue
+			}
+			b.Pos = vec3.Add(b.Pos, vec3.Scale(n, depth))
+
+			vn := vec3.Dot(vec3.Sub(b.Vel, o.Vel), n)
+			if vn >= 0 {
+				continue
+			}
+			b.Vel = vec3.Sub(b.Vel, vec3.Scale(n, 2*vn))
+			c.hitSurface(vec3.Sub(b.Pos, vec3.Scale(n, r)), n)
+		}
+	}
+}
+
+func (c *Game) drawObstacles(pln int) {
+	for i := range c.Obstacles {
+		p := c.Obstacles[i].Pos
+		s := ga.Vec3d{c.ObstacleSize, c.ObstacleSize, c.ObstacleSize}
+		c.drawBox(pln, vec3.Sub(p, s), vec3.Add(p, s), obstacleColor)
+	}
+}
+
+// drawBox draws a box in the arena from min to max.
+func (c *Game) drawBox(pln int, min, max ga.Vec3d, col color.RGBA) {
+	var v [8]ga.Vec3d
+	for i := range v {
+		p := min
+		if i&1 != 0 {
+			p.X = max.X
+		}
+		if i&2 != 0 {
+			p.Y = max.Y
+		}
+		if i&4 != 0 {
+			p.Z = max.Z
+		}
+		v[i] = c.toView(pln, p)
+	}
+
+	faces := [6][4]int{
+		{0, 1, 3, 2}, {4, 5, 7, 6},
+		{0, 1, 5, 4}, {2, 3, 7, 6},
+		{0, 2, 6, 4}, {1, 3, 7, 5},
+	}
+	for _, f := range faces {
+		c.drawFace(pln, col, v[f[0]], v[f[1]], v[f[2]], v[f[3]])
+	}
+
+	// Each edge joins two corners that differ in one axis
+	for i := range v {
+		for _, bit := range [...]int{1, 2, 4} {
+			if i&bit == 0 {
+				c.drawLine(pln, v[i], v[i|bit], col)
+			}
+		}
+	}
+}
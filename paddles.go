@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+// How a paddle sends the ball back
+const (
+	// The further from the middle of the paddle, the steeper the angle
+	PADDLE_FLAT = iota
+	// The paddle bulges out, the ball bounces off it like a mirror
+	PADDLE_CURVED
+	// Like flat, but the ball also picks up the speed of the paddle
+	PADDLE_MOMENTUM
+	NUM_PADDLE_MODELS
+)
+
+var paddleModelNames = [NUM_PADDLE_MODELS]string{"Flat", "Curved", "Momentum"}
+
+const (
+	// How much the edge of a curved paddle is turned away from its middle
+	PADDLE_CURVE = 0.6
+
+	// How much of the speed of the paddle goes into the ball and its spin
+	PADDLE_MOMENTUM_TRANSFER = 0.5
+	PADDLE_SPIN              = 0.2
+
+	// How fast a paddle has to swing into the ball for a smash,
+	// and how much faster a smash sends it back
+	SMASH_SPEED = 10
+	SMASH_BOOST = 1.5
+)
+
+// trackPaddles remembers how far each paddle moved since the last
+// tick, their speed is the average over the queue.
+func (c *Game) trackPaddles() {
+	for seat := range c.Player {
+		q := c.Queue[seat]
+		if len(q) == 0 {
+			continue
+		}
+		q[c.QueuePos[seat]] = ga.Vec2d{
+			c.Player[seat].X - c.LastPlayer[seat].X,
+			c.Player[seat].Y - c.LastPlayer[seat].Y,
+		}
+		c.QueuePos[seat] = (c.QueuePos[seat] + 1) % len(q)
+		c.LastPlayer[seat] = c.Player[seat]
+	}
+}
+
+// deflect sends a ball that hit the paddle of a seat back, p and v are the
+// position and velocity of the ball seen from the seat and v.Z is already
+// the speed it leaves with. It reports whether the hit was a smash.
+func (c *Game) deflect(b *Ball, seat int, p ga.Vec3d, v *ga.Vec3d) bool {
+	paddle := c.Player[seat]
+	size := c.paddleSize(seat)
+	off := ga.Vec2d{p.X - paddle.X, p.Y - paddle.Y}
+	speed := v.Z
+
+	switch c.PaddleModel {
+	case PADDLE_FLAT:
+		v.X = off.X / c.AngleDivide
+		v.Y = off.Y / c.AngleDivide
+
+	case PADDLE_CURVED:
+		// Mirror the ball off the bulge where it hit, keeping the speed along Z
+		n := vec3.Normalize(ga.Vec3d{
+			off.X / size.X * PADDLE_CURVE,
+			off.Y / size.Y * PADDLE_CURVE,
+			1,
+		})
+		in := c.worldToSeat(seat, b.Vel)
+		out := vec3.Sub(in, vec3.Scale(n, 2*vec3.Dot(in, n)))
+		if out.Z > 0 {
+			out = vec3.Scale(out, speed/out.Z)
+		}
+		v.X, v.Y = out.X, out.Y
+
+	case PADDLE_MOMENTUM:
+		pv := c.total(c.Queue[seat])
+		v.X = off.X/c.AngleDivide + pv.X*PADDLE_MOMENTUM_TRANSFER
+		v.Y = off.Y/c.AngleDivide + pv.Y*PADDLE_MOMENTUM_TRANSFER
+		b.Spin = c.seatToWorld(seat, ga.Vec3d{pv.X * PADDLE_SPIN, pv.Y * PADDLE_SPIN, 0})
+	}
+
+	// Swinging the paddle hard into the ball smashes it
+	pv := c.total(c.Queue[seat])
+	if math.Hypot(pv.X, pv.Y) >= SMASH_SPEED && pv.X*off.X+pv.Y*off.Y > 0 {
+		v.Z *= SMASH_BOOST
+		return true
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"image/color"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+// A Curve maps how far through its life a particle is, from 0 to 1,
+// to a multiplier, a nil curve is always 1.
+type Curve func(t float64) float64
+
+func curveLinear(t float64) float64  { return 1 - t }
+func curveEaseOut(t float64) float64 { return (1 - t) * (1 - t) }
+
+func (f Curve) at(t float64) float64 {
+	if f == nil {
+		return 1
+	}
+	return f(t)
+}
+
+// A Gradient is a list of colors over the life of a particle,
+// the stops are sorted by time from 0 to 1.
+type Gradient []GradientStop
+
+type GradientStop struct {
+	T     float64
+	Color color.RGBA
+}
+
+func (g Gradient) at(t float64) color.RGBA {
+	if len(g) == 0 {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	if t <= g[0].T {
+		return g[0].Color
+	}
+	for i := 1; i < len(g); i++ {
+		if t <= g[i].T {
+			a, b := g[i-1], g[i]
+			return lerpColor((t-a.T)/(b.T-a.T), a.Color, b.Color)
+		}
+	}
+	return g[len(g)-1].Color
+}
+
+// An Emitter describes a burst of particles.
+type Emitter struct {
+	// How many particles and how many ticks each one lives for
+	Count [2]int
+	Life  [2]int
+
+	// Particles leave along Dir at Speed, with a random direction
+	// added on top, Spread 0 goes straight and 1 goes anywhere
+	Dir    ga.Vec3d
+	Speed  float64
+	Spread float64
+
+	// Random offset from the emitter position
+	Jitter float64
+
+	// How much gravity pulls on it and how much speed
+	// it keeps when it bounces off a wall
+	Gravity float64
+	Bounce  float64
+
+	Gradient Gradient
+
+	// Brightness and streak length over the life of a particle
+	Fade    Curve
+	Stretch Curve
+}
+
+// A Particle is a streak drawn from its position along its velocity.
+type Particle struct {
+	Pos     ga.Vec3d
+	Vel     ga.Vec3d
+	Age     int
+	Life    int
+	Emitter *Emitter
+}
+
+// emit adds a burst of particles at pos, inheriting vel.
+func (c *Game) emit(e *Emitter, pos, vel ga.Vec3d) {
+	n := e.Count[0]
+	if e.Count[1] > e.Count[0] {
+		n += rand.Intn(e.Count[1] - e.Count[0] + 1)
+	}
+	for i := 0; i < n; i++ {
+		life := e.Life[0]
+		if e.Life[1] > e.Life[0] {
+			life += rand.Intn(e.Life[1] - e.Life[0] + 1)
+		}
+		if life <= 0 {
+			continue
+		}
+
+		v := vec3.Add(vel, vec3.Scale(e.Dir, e.Speed))
+		v = vec3.Add(v, vec3.Scale(randomUnit(), e.Speed*e.Spread*(0.5+rand.Float64()/2)))
+		p := vec3.Add(pos, vec3.Scale(randomUnit(), e.Jitter*rand.Float64()))
+
+		// The append fills the spare capacity left behind by the
+		// particles moveParticles dropped before the pool grows
+		c.Particles = append(c.Particles, Particle{
+			Pos:     p,
+			Vel:     v,
+			Life:    life,
+			Emitter: e,
+		})
+	}
+}
+
+// randomUnit is a random direction with no bias towards any axis.
+func randomUnit() ga.Vec3d {
+	for {
+		v := ga.Vec3d{rand.Float64()*2 - 1, rand.Float64()*2 - 1, rand.Float64()*2 - 1}
+		if l := vec3.Len(v); l > 0.01 && l <= 1 {
+			return vec3.Scale(v, 1/l)
+		}
+	}
+}
+
+// moveParticles ages and moves the particles and bounces them
+// off the arena, dead ones are swapped out of the live part of the pool.
+func (c *Game) moveParticles() {
+	g := c.gravity()
+	a := c.Arena
+	ps := c.Particles
+	for i := 0; i < len(ps); {
+		p := &ps[i]
+		if p.Age++; p.Age >= p.Life {
+			ps[i] = ps[len(ps)-1]
+			ps = ps[:len(ps)-1]
+			continue
+		}
+
+		e := p.Emitter
+		p.Vel = vec3.Add(p.Vel, vec3.Scale(g, e.Gravity))
+		p.Pos = vec3.Add(p.Pos, p.Vel)
+		bounce(&p.Pos.X, &p.Vel.X, a.X, e.Bounce)
+		bounce(&p.Pos.Y, &p.Vel.Y, a.Y, e.Bounce)
+		bounce(&p.Pos.Z, &p.Vel.Z, a.Z, e.Bounce)
+		i++
+	}
+	c.Particles = ps
+}
+
+// bounce keeps x inside of -limit and limit, reflecting v off the edge.
+func bounce(x, v *float64, limit, restitution float64) {
+	if *x < -limit {
+		*x = -limit
+		*v = -*v * restitution
+	} else if *x > limit {
+		*x = limit
+		*v = -*v * restitution
+	}
+}
+
+func (c *Game) drawParticles(pln int) {
+	for i := range c.Particles {
+		p := &c.Particles[i]
+		e := p.Emitter
+		t := float64(p.Age) / float64(p.Life)
+		col := shade(e.Gradient.at(t), e.Fade.at(t))
+		tail := vec3.Add(p.Pos, vec3.Scale(p.Vel, e.Stretch.at(t)))
+		c.drawLine(pln, c.toView(pln, p.Pos), c.toView(pln, tail), col)
+	}
+}
+
+// addDebris bursts debris off a ball where it hit a paddle.
+func (c *Game) addDebris(side int, b *Ball) {
+	e := &Emitter{
+		Count:   [2]int{c.DebrisMin, c.DebrisMax},
+		Life:    [2]int{c.DebrisTime / 2, c.DebrisTime},
+		Speed:   float64(c.DebrisSpeed),
+		Spread:  1,
+		Jitter:  5,
+		Gravity: 0.5,
+		Bounce:  0.5,
+		Fade:    curveLinear,
+		Gradient: Gradient{
+			{0, color.RGBA{255, 255, 255, 255}},
+			{0.3, c.seatColor(0, side, false)},
+			{1, c.seatColor(0, side, true)},
+		},
+	}
+	c.emit(e, b.Pos, vec3.Scale(b.Vel, 0.5))
+}
+
+// addSparks throws sparks off a wall where the ball hit it.
+func (c *Game) addSparks(pos, normal ga.Vec3d) {
+	e := sparkEmitter
+	e.Dir = normal
+	c.emit(&e, pos, ga.Vec3d{})
+}
+
+// addScoreBurst celebrates a point with a burst in the color of the scorer.
+func (c *Game) addScoreBurst(side int, pos ga.Vec3d) {
+	e := &Emitter{
+		Count:   [2]int{40, 60},
+		Life:    [2]int{40, 80},
+		Speed:   4,
+		Spread:  1,
+		Gravity: 0.3,
+		Bounce:  0.7,
+		Fade:    curveEaseOut,
+		Stretch: curveLinear,
+		Gradient: Gradient{
+			{0, color.RGBA{255, 255, 255, 255}},
+			{0.2, c.seatColor(0, side, false)},
+			{1, c.seatColor(0, side, true)},
+		},
+	}
+	c.emit(e, pos, ga.Vec3d{})
+}
+
+var sparkEmitter = Emitter{
+	Count:   [2]int{6, 10},
+	Life:    [2]int{8, 16},
+	Speed:   3,
+	Spread:  0.8,
+	Gravity: 1,
+	Bounce:  0.3,
+	Fade:    curveLinear,
+	Stretch: curveLinear,
+	Gradient: Gradient{
+		{0, color.RGBA{255, 255, 200, 255}},
+		{0.5, color.RGBA{255, 160, 0, 255}},
+		{1, color.RGBA{128, 0, 0, 255}},
+	},
+}
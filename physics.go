@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+// How the ball moves, the arcade model of the original game or one
+// with drag, friction and a speed limit
+const (
+	PHYSICS_ARCADE = iota
+	PHYSICS_REAL
+	NUM_PHYSICS
+)
+
+var physicsNames = [NUM_PHYSICS]string{"Arcade", "Physical"}
+
+const (
+	// How much spin the ball picks up for the speed friction
+	// takes away along a surface
+	FRICTION_SPIN = 0.5
+)
+
+// bounceSurface bounces a ball moving at v into a surface with normal n,
+// vn is the speed going into it. The arcade model only flips the speed
+// along the normal, the physical one also loses some of it to the
+// restitution and friction slows it along the surface and spins it.
+func (c *Game) bounceSurface(v, spin *ga.Vec3d, n ga.Vec3d, vn float64, s Surface) {
+	if c.Physics == PHYSICS_ARCADE {
+		*v = vec3.Sub(*v, vec3.Scale(n, vn*(1+s.Bounce)))
+		return
+	}
+
+	e := s.Bounce * c.Restitution
+	vt := vec3.Sub(*v, vec3.Scale(n, vn))
+
+	// Friction can at most stop the ball sliding along the surface
+	var loss ga.Vec3d
+	if l := vec3.Len(vt); l > 0 {
+		f := math.Min(s.Friction*(1+e)*-vn, l)
+		loss = vec3.Scale(vt, f/l)
+	}
+	*v = vec3.Add(vec3.Sub(vt, loss), vec3.Scale(n, -vn*e))
+
+	// Rubbing along the surface spins the ball around n x slip, like
+	// the Magnus effect that bends its path across where it is going
+	if spin != nil && vec3.Len(*v) > 0 {
+		w := vec3.Cross(n, loss)
+		curve := vec3.Cross(w, vec3.Normalize(*v))
+		*spin = vec3.Add(*spin, vec3.Scale(curve, FRICTION_SPIN))
+	}
+}
+
+// airBall slows a ball down with drag and keeps it under the speed limit.
+func (c *Game) airBall(b *Ball) {
+	if c.Physics == PHYSICS_ARCADE {
+		return
+	}
+	b.Vel = vec3.Scale(b.Vel, 1-c.Drag)
+	if l := vec3.Len(b.Vel); l > c.MaxSpeed {
+		b.Vel = vec3.Scale(b.Vel, c.MaxSpeed/l)
+	}
+}
+
+// paddleSpeed is how fast a paddle sends the ball back along its axis,
+// v is how fast the ball came in. The arcade model picks a random speed
+// that goes up with every hit, the physical one bounces the ball back
+// with the restitution plus a kick from the paddle.
+func (c *Game) paddleSpeed(b *Ball, v float64) float64 {
+	if c.Physics == PHYSICS_ARCADE {
+		return float64(rand.Intn(int(b.Speed/2))) + b.Speed/2
+	}
+	return math.Abs(v)*c.Restitution + c.InitialBallSpeed/2
+}
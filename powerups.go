@@ -0,0 +1,475 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+)
+
+const (
+	POWER_GROW = iota
+	POWER_SHRINK
+	POWER_SLOW
+	POWER_FAST
+	POWER_INVISIBLE
+	POWER_MAGNET
+	POWER_GRAVITY_FLIP
+	POWER_SHIELD
+	POWER_MULTIBALL
+	NUM_POWERS
+)
+
+// How a power-up combines with one of the same kind that is still active
+const (
+	// Start the time over
+	STACK_REFRESH = iota
+	// Add the time on top of what is left
+	STACK_EXTEND
+	// Add another stack, up to the limit, the effect gets stronger
+	STACK_COUNT
+)
+
+// Who a power-up is for, the player who hit the ball last or their opponents
+const (
+	TARGET_SELF = iota
+	TARGET_OPPONENT
+)
+
+const (
+	// Half size of the power-ups floating in the arena
+	POWERUP_SIZE = 10
+
+	// Most power-ups floating in the arena at once
+	MAX_POWERUPS = 3
+
+	// How much each stack of grow or shrink changes the paddle size
+	PADDLE_GROWTH = 0.4
+
+	// How much slow and fast change the speed of the balls
+	SLOW_FACTOR = 0.6
+	FAST_FACTOR = 1.5
+
+	// How hard a magnet pulls the ball toward the paddle
+	MAGNET_PULL = 0.02
+)
+
+// A PowerDef describes what a kind of power-up does, the effects
+// themselves are looked up by kind where they change the game.
+type PowerDef struct {
+	Name   string
+	Icon   string
+	Color  color.RGBA
+	Target int
+
+	// Seconds the effect lasts, 0 is used up right away
+	Duration float64
+	Stack    int
+	MaxStack int
+
+	// Only makes sense with an opponent
+	Versus bool
+
+	// Called when the effect starts with the ball that picked it up,
+	// and when it ends
+	Start func(c *Game, p *Power, b *Ball)
+	End   func(c *Game, p *Power)
+}
+
+var powerDefs = [NUM_POWERS]PowerDef{
+	POWER_GROW: {
+		Name: "Grow", Icon: "+", Color: color.RGBA{0, 255, 128, 255},
+		Target: TARGET_SELF, Duration: 10, Stack: STACK_COUNT, MaxStack: 2,
+	},
+	POWER_SHRINK: {
+		Name: "Shrink", Icon: "-", Color: color.RGBA{255, 64, 64, 255},
+		Target: TARGET_OPPONENT, Duration: 10, Stack: STACK_COUNT, MaxStack: 2, Versus: true,
+	},
+	POWER_SLOW: {
+		Name: "Slow", Icon: "<", Color: color.RGBA{64, 160, 255, 255},
+		Target: TARGET_SELF, Duration: 8, Stack: STACK_REFRESH,
+	},
+	POWER_FAST: {
+		Name: "Fast", Icon: ">", Color: color.RGBA{255, 160, 0, 255},
+		Target: TARGET_SELF, Duration: 8, Stack: STACK_REFRESH,
+	},
+	POWER_INVISIBLE: {
+		Name: "Invisible", Icon: "?", Color: color.RGBA{160, 160, 160, 255},
+		Target: TARGET_SELF, Duration: 5, Stack: STACK_REFRESH, Versus: true,
+	},
+	POWER_MAGNET: {
+		Name: "Magnet", Icon: "M", Color: color.RGBA{255, 0, 255, 255},
+		Target: TARGET_SELF, Duration: 8, Stack: STACK_EXTEND,
+	},
+	POWER_GRAVITY_FLIP: {
+		Name: "Gravity flip", Icon: "G", Color: color.RGBA{255, 255, 0, 255},
+		Target: TARGET_SELF, Duration: 6, Stack: STACK_REFRESH,
+	},
+	POWER_SHIELD: {
+		Name: "Shield", Icon: "#", Color: color.RGBA{0, 255, 255, 255},
+		Target: TARGET_SELF, Duration: 15, Stack: STACK_COUNT, MaxStack: 3,
+	},
+	POWER_MULTIBALL: {
+		Name: "Multi-ball", Icon: "*", Color: color.RGBA{255, 255, 255, 255},
+		Target: TARGET_SELF,
+		Start: func(c *Game, p *Power, b *Ball) {
+			if len(c.Balls) < c.MultiBall {
+				c.Balls = append(c.Balls, c.splitBall(b))
+			}
+		},
+	},
+}
+
+// A PowerUp floats in the arena until a ball goes through it.
+type PowerUp struct {
+	Kind int
+	Pos  ga.Vec3d
+	Age  int
+}
+
+// A Power is an effect that is active for one side.
+type Power struct {
+	Kind   int
+	Side   int
+	Time   int
+	Stacks int
+}
+
+// movePowerUps spawns power-ups every so often and
+// gives their effect to whoever sent a ball through one.
+func (c *Game) movePowerUps() {
+	c.agePowers()
+	if !c.PowerUps {
+		return
+	}
+
+	for i := range c.Floating {
+		c.Floating[i].Age++
+	}
+
+	c.PowerTimer++
+	if c.BallInPlay && float64(c.PowerTimer) >= c.PowerUpInterval*c.Config.TickRate && len(c.Floating) < MAX_POWERUPS {
+		c.PowerTimer = 0
+		c.spawnPowerUp()
+	}
+
+	r := c.BallSize + POWERUP_SIZE
+	floating := c.Floating[:0]
+	for _, u := range c.Floating {
+		taken := false
+		for i := range c.Balls {
+			b := &c.Balls[i]
+			d := ga.Vec3d{b.Pos.X - u.Pos.X, b.Pos.Y - u.Pos.Y, b.Pos.Z - u.Pos.Z}
+			if d.X*d.X+d.Y*d.Y+d.Z*d.Z <= r*r {
+				// Adding a ball moves the others, so stop looking at them
+				c.givePower(u.Kind, b.LastHit, b)
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			floating = append(floating, u)
+		}
+	}
+	c.Floating = floating
+}
+
+// spawnPowerUp puts a random power-up that suits the mode
+// somewhere in the middle of the arena.
+func (c *Game) spawnPowerUp() {
+	var kinds []int
+	for k := range powerDefs {
+		// Multi-ball only shows up when more than one ball is allowed
+		if k == POWER_MULTIBALL && c.MultiBall < 2 {
+			continue
+		}
+		if !powerDefs[k].Versus || c.Mode != HANDBALL && c.Mode != PRACTICE {
+			kinds = append(kinds, k)
+		}
+	}
+
+	a := c.Arena
+	u := PowerUp{
+		Kind: kinds[rand.Intn(len(kinds))],
+		Pos: ga.Vec3d{
+			(rand.Float64()*2 - 1) * (a.X - POWERUP_SIZE),
+			(rand.Float64()*2 - 1) * (a.Y - POWERUP_SIZE),
+			(rand.Float64()*2 - 1) * a.Z / 2,
+		},
+	}
+	c.Floating = append(c.Floating, u)
+}
+
+// givePower starts the effect of a power-up for the player who
+// hit the ball last, or everyone playing against them depending on the kind.
+func (c *Game) givePower(kind, side int, b *Ball) {
+	c.playSound("hit")
+	if powerDefs[kind].Target == TARGET_SELF {
+		c.startPower(kind, side, b)
+		return
+	}
+	for seat := 0; seat < c.seats(); seat++ {
+		if c.team(seat) != c.team(side) && c.guarded(seat) {
+			c.startPower(kind, seat, b)
+		}
+	}
+}
+
+// startPower starts or stacks an effect for one side.
+func (c *Game) startPower(kind, side int, b *Ball) {
+	d := &powerDefs[kind]
+	for i := range c.Powers {
+		p := &c.Powers[i]
+		if p.Kind != kind || p.Side != side {
+			continue
+		}
+		switch d.Stack {
+		case STACK_REFRESH:
+			p.Time = c.powerTicks(d)
+		case STACK_EXTEND:
+			p.Time += c.powerTicks(d)
+		case STACK_COUNT:
+			p.Time = c.powerTicks(d)
+			if p.Stacks < d.MaxStack {
+				p.Stacks++
+			}
+		}
+		return
+	}
+
+	p := Power{Kind: kind, Side: side, Time: c.powerTicks(d), Stacks: 1}
+	if d.Start != nil {
+		d.Start(c, &p, b)
+	}
+	if d.Duration > 0 {
+		c.Powers = append(c.Powers, p)
+	}
+}
+
+// powerTicks is how many ticks an effect lasts.
+func (c *Game) powerTicks(d *PowerDef) int {
+	return int(d.Duration * c.Config.TickRate)
+}
+
+// agePowers ends the effects that ran out of time.
+func (c *Game) agePowers() {
+	powers := c.Powers[:0]
+	for _, p := range c.Powers {
+		if p.Time--; p.Time > 0 {
+			powers = append(powers, p)
+			continue
+		}
+		if d := &powerDefs[p.Kind]; d.End != nil {
+			d.End(c, &p)
+		}
+	}
+	c.Powers = powers
+}
+
+// clearPowers ends every effect and removes the floating power-ups.
+func (c *Game) clearPowers() {
+	for i := range c.Powers {
+		p := &c.Powers[i]
+		if d := &powerDefs[p.Kind]; d.End != nil {
+			d.End(c, p)
+		}
+	}
+	c.Powers = c.Powers[:0]
+	c.Floating = c.Floating[:0]
+	c.PowerTimer = 0
+}
+
+// power returns how many stacks of an effect a side has, 0 if none.
+func (c *Game) power(kind, side int) int {
+	for i := range c.Powers {
+		p := &c.Powers[i]
+		if p.Kind == kind && p.Side == side {
+			return p.Stacks
+		}
+	}
+	return 0
+}
+
+// usePower takes away one stack of an effect.
+func (c *Game) usePower(kind, side int) {
+	for i := range c.Powers {
+		p := &c.Powers[i]
+		if p.Kind == kind && p.Side == side {
+			if p.Stacks--; p.Stacks <= 0 {
+				p.Time = 1
+			}
+			return
+		}
+	}
+}
+
+// ballPace is how far the balls go each tick for their speed while
+// anyone has slow or fast, the speed itself is left alone so hits,
+// serves and splits during the effect keep working as usual.
+func (c *Game) ballPace() float64 {
+	pace := 1.0
+	for side := 0; side < MAX_SEATS; side++ {
+		if c.power(POWER_SLOW, side) != 0 {
+			pace *= SLOW_FACTOR
+		}
+		if c.power(POWER_FAST, side) != 0 {
+			pace *= FAST_FACTOR
+		}
+	}
+	return pace
+}
+
+// paddleSize is the size of the paddle of a side with grow and shrink.
+func (c *Game) paddleSize(side int) ga.Vec2d {
+	s := 1 + PADDLE_GROWTH*float64(c.power(POWER_GROW, side)-c.power(POWER_SHRINK, side))
+	s = math.Max(s, 0.2)
+	if side == 0 && c.rules() != nil {
+		s *= c.PaddleScale
+	}
+	return ga.Vec2d{c.PaddleSize.X * s, c.PaddleSize.Y * s}
+}
+
+// ballHidden reports whether the balls are hidden from a player,
+// someone playing against them made them invisible.
+func (c *Game) ballHidden(pln int) bool {
+	if c.Mode == HANDBALL {
+		return false
+	}
+	own := c.ViewSeat[pln]
+	for seat := 0; seat < MAX_SEATS; seat++ {
+		if c.team(seat) != c.team(own) && c.power(POWER_INVISIBLE, seat) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// magnet pulls a ball headed toward a side with a magnet toward its paddle.
+func (c *Game) magnet(b *Ball) {
+	for side := 0; side < MAX_SEATS; side++ {
+		if c.power(POWER_MAGNET, side) == 0 {
+			continue
+		}
+		p := c.Player[side]
+		q := c.worldToSeat(side, b.Pos)
+		v := c.worldToSeat(side, b.Vel)
+		if v.Z < 0 {
+			v.X += (p.X - q.X) * MAGNET_PULL
+			v.Y += (p.Y - q.Y) * MAGNET_PULL
+			b.Vel = c.seatToWorld(side, v)
+		}
+	}
+}
+
+// shield bounces a ball back that got past the paddle of a side
+// with a shield, using up one stack of it.
+func (c *Game) shield(b *Ball, side int) bool {
+	if c.power(POWER_SHIELD, side) == 0 {
+		return false
+	}
+	c.usePower(POWER_SHIELD, side)
+
+	z := -c.seatSize(side).Z
+	q := c.worldToSeat(side, b.Pos)
+	v := c.worldToSeat(side, b.Vel)
+	q.Z = z + c.BallSize
+	v.Z = -v.Z
+	b.Pos = c.seatToWorld(side, q)
+	b.Vel = c.seatToWorld(side, v)
+	c.hitSurface(c.seatToWorld(side, ga.Vec3d{q.X, q.Y, z}), c.seatToWorld(side, ga.Vec3d{0, 0, 1}))
+	return true
+}
+
+func (c *Game) drawPowerUps(pln int) {
+	for _, u := range c.Floating {
+		d := &powerDefs[u.Kind]
+
+		// Spin and bob so they stand out from the slabs
+		angle := float64(u.Age) * 0.05
+		bob := math.Sin(float64(u.Age)*0.08) * POWERUP_SIZE / 4
+		sin, cos := math.Sincos(angle)
+		s := float64(POWERUP_SIZE)
+		p := ga.Vec3d{u.Pos.X, u.Pos.Y + bob, u.Pos.Z}
+
+		ring := [4]ga.Vec3d{
+			{p.X + cos*s, p.Y, p.Z + sin*s},
+			{p.X - sin*s, p.Y, p.Z + cos*s},
+			{p.X - cos*s, p.Y, p.Z - sin*s},
+			{p.X + sin*s, p.Y, p.Z - cos*s},
+		}
+		tips := [2]ga.Vec3d{{p.X, p.Y - s, p.Z}, {p.X, p.Y + s, p.Z}}
+		for i := range ring {
+			ring[i] = c.toView(pln, ring[i])
+		}
+		for i := range tips {
+			tips[i] = c.toView(pln, tips[i])
+		}
+
+		fill := d.Color
+		fill.A = PADDLE_ALPHA
+		for _, tip := range tips {
+			for i := range ring {
+				j := (i + 1) % len(ring)
+				c.drawFace(pln, fill, ring[i], ring[j], tip)
+				c.drawLine(pln, ring[i], tip, d.Color)
+			}
+		}
+		for i := range ring {
+			c.drawLine(pln, ring[i], ring[(i+1)%len(ring)], d.Color)
+		}
+	}
+}
+
+// drawShields draws a line across the back of the paddle plane of
+// each side with a shield, one line for each stack.
+func (c *Game) drawShields(pln int) {
+	for side := 0; side < MAX_SEATS; side++ {
+		n := c.power(POWER_SHIELD, side)
+		a := c.seatSize(side)
+		x, y, z := a.X, a.Y, -a.Z
+		col := powerDefs[POWER_SHIELD].Color
+		line := func(p, q ga.Vec3d) {
+			c.drawLine(pln, c.toView(pln, c.seatToWorld(side, p)), c.toView(pln, c.seatToWorld(side, q)), col)
+		}
+		for i := 0; i < n; i++ {
+			h := y - float64(i)*y/4
+			line(ga.Vec3d{-x, h, z}, ga.Vec3d{x, h, z})
+			line(ga.Vec3d{-x, -h, z}, ga.Vec3d{x, -h, z})
+		}
+	}
+}
+
+// drawPowers shows the effects each side has as icons in the top right,
+// the ones a player has first and then the ones everyone else has.
+func (c *Game) drawPowers(pln int) {
+	fh := c.FontHeight
+	x := c.Bound[pln].Dx() - c.px(TEXT_MARGIN)
+	y := fh
+	own := c.ViewSeat[pln]
+	for i := 0; i < MAX_SEATS; i++ {
+		side := (own + i) % MAX_SEATS
+		for _, p := range c.Powers {
+			if p.Side != side {
+				continue
+			}
+			d := &powerDefs[p.Kind]
+			col := d.Color
+			if side != own {
+				col = shade(col, 0.6)
+			}
+
+			text := fmt.Sprintf("[%s] %s", d.Icon, d.Name)
+			if p.Stacks > 1 {
+				text += fmt.Sprintf(" x%d", p.Stacks)
+			}
+			if side != own {
+				text = fmt.Sprintf("P%d %s", side+1, text)
+			}
+			secs := math.Ceil(float64(p.Time) / c.Config.TickRate)
+			c.drawTextAligned(pln, ALIGN_RIGHT, x, y, col, "%s %.0f", text, secs)
+			y += fh
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+// Where the launcher sends the balls in practice mode
+const (
+	DRILL_RANDOM = iota
+	DRILL_CORNERS
+	DRILL_SCRIPT
+	NUM_DRILLS
+)
+
+var drillNames = [NUM_DRILLS]string{"Random", "Corners", "Script"}
+
+const (
+	// How far into the corners the corner drill aims
+	DRILL_CORNER = 0.8
+
+	// The seat the launcher stands in
+	LAUNCHER_SEAT = 1
+)
+
+var (
+	launcherColor = color.RGBA{255, 165, 0, 255}
+	returnColor   = color.RGBA{255, 255, 255, 255}
+)
+
+// The corners the corner drill goes through, switching sides every shot
+var drillCorners = [...]ga.Vec2d{
+	{-DRILL_CORNER, DRILL_CORNER},
+	{DRILL_CORNER, DRILL_CORNER},
+	{-DRILL_CORNER, -DRILL_CORNER},
+	{DRILL_CORNER, -DRILL_CORNER},
+}
+
+// A Shot is one ball from the launcher. Target, Height and Return go from
+// -1 to 1 across the arena with up being positive.
+type Shot struct {
+	// Where on your end the ball is aimed
+	Target ga.Vec2d
+
+	// How fast it comes at you, zero uses the speed in the config
+	Speed float64
+
+	// How much it curves sideways and up or down
+	Spin ga.Vec2d
+
+	// How high up the far end it is launched from
+	Height float64
+
+	// Where on the far end to send it back
+	Return ga.Vec2d
+}
+
+// The script drill until the config has its own
+var defaultDrillScript = []Shot{
+	{Target: ga.Vec2d{0, 0}},
+	{Target: ga.Vec2d{-0.5, 0.5}, Return: ga.Vec2d{0.5, -0.5}},
+	{Target: ga.Vec2d{0.5, 0.5}, Return: ga.Vec2d{-0.5, -0.5}},
+	{Target: ga.Vec2d{0, -0.8}, Speed: 6, Height: 0.8},
+	{Target: ga.Vec2d{0.5, 0}, Spin: ga.Vec2d{-2, 0}},
+}
+
+// DrillStats is how well a drill went.
+type DrillStats struct {
+	Shots   int
+	Hits    int
+	Returns int
+
+	// Sum of how close every return came to the spot it was
+	// meant for, 1 is dead on
+	Accuracy float64
+}
+
+// resetDrills clears the stats of every drill and has the launcher serve.
+func (c *Game) resetDrills() {
+	c.DrillStats = [NUM_DRILLS]DrillStats{}
+	c.ShotIndex = 0
+	c.LaunchTimer = 0
+	c.Shot = Shot{Height: c.Config.LaunchHeight}
+	if c.Mode == PRACTICE {
+		c.BallWaitingFor = LAUNCHER_SEAT
+	}
+}
+
+// nextShot picks the next shot of the drill.
+func (c *Game) nextShot() Shot {
+	f := &c.Config
+	s := Shot{
+		Speed:  f.LaunchSpeed,
+		Spin:   f.LaunchSpin,
+		Height: f.LaunchHeight,
+	}
+	switch c.Drill {
+	case DRILL_RANDOM:
+		s.Target = ga.Vec2d{rand.Float64()*2 - 1, rand.Float64()*2 - 1}
+
+	case DRILL_CORNERS:
+		s.Target = drillCorners[c.ShotIndex%len(drillCorners)]
+
+	case DRILL_SCRIPT:
+		if len(f.DrillScript) == 0 {
+			break
+		}
+		s = f.DrillScript[c.ShotIndex%len(f.DrillScript)]
+		if s.Speed == 0 {
+			s.Speed = f.LaunchSpeed
+		}
+	}
+	c.ShotIndex++
+	return s
+}
+
+// launch fires the next shot of the drill at the player.
+func (c *Game) launch() Ball {
+	s := c.nextShot()
+	c.Shot = s
+	c.DrillStats[c.Drill].Shots++
+
+	a := c.Arena
+	r := c.BallSize
+	from := c.launcherPos()
+	to := ga.Vec3d{s.Target.X * (a.X - r), -s.Target.Y * (a.Y - r), -a.Z}
+	d := vec3.Sub(to, from)
+
+	return Ball{
+		Pos:     from,
+		Vel:     vec3.Scale(d, s.Speed/-d.Z),
+		Speed:   c.InitialBallSpeed,
+		Spin:    ga.Vec3d{s.Spin.X, -s.Spin.Y, 0},
+		LastHit: LAUNCHER_SEAT,
+	}
+}
+
+// launcherPos is where the launcher fires the balls from.
+func (c *Game) launcherPos() ga.Vec3d {
+	a := c.Arena
+	r := c.BallSize
+	return ga.Vec3d{0, -c.Shot.Height * (a.Y - r), a.Z - r*2}
+}
+
+// returnPos is where the player should send the ball back to.
+func (c *Game) returnPos() ga.Vec3d {
+	a := c.Arena
+	r := c.BallSize
+	return ga.Vec3d{c.Shot.Return.X * (a.X - r), -c.Shot.Return.Y * (a.Y - r), a.Z}
+}
+
+// moveLauncher serves the next ball once the last one is out of play.
+func (c *Game) moveLauncher() {
+	if c.Mode != PRACTICE || c.BallInPlay {
+		return
+	}
+	if c.LaunchTimer++; float64(c.LaunchTimer) >= c.LaunchInterval*c.Config.TickRate {
+		c.LaunchTimer = 0
+		c.putBallInPlay(LAUNCHER_SEAT)
+	}
+}
+
+// returnBall scores a ball that got back to the launcher, the
+// closer to the return spot of the shot the better.
+func (c *Game) returnBall(b *Ball) {
+	if b.LastHit == LAUNCHER_SEAT {
+		return
+	}
+	a := c.Arena
+	p := c.returnPos()
+	dist := math.Hypot(b.Pos.X-p.X, b.Pos.Y-p.Y)
+
+	d := &c.DrillStats[c.Drill]
+	d.Returns++
+	d.Accuracy += math.Max(0, 1-dist/math.Hypot(a.X, a.Y))
+
+	if dist <= c.PaddleSize.X {
+		c.playSound("score")
+		c.addScoreBurst(0, b.Pos)
+	} else {
+		c.playSound("wall")
+	}
+}
+
+// drawLauncher draws the launcher and the spot to send the ball back to.
+func (c *Game) drawLauncher(pln int) {
+	if c.Mode != PRACTICE {
+		return
+	}
+	none := color.RGBA{}
+	c.drawRing(pln, PLANE_XY, c.toView(pln, c.launcherPos()), c.BallSize*2, launcherColor, none)
+	c.drawRing(pln, PLANE_XY, c.toView(pln, c.returnPos()), c.PaddleSize.X, returnColor, none)
+}
+
+// drawDrills shows the hit rate and accuracy of every drill played,
+// the current one first.
+func (c *Game) drawDrills(pln int) {
+	fh := c.FontHeight
+	x := c.px(TEXT_MARGIN)
+	y := fh
+	for i := 0; i < NUM_DRILLS; i++ {
+		drill := (c.Drill + i) % NUM_DRILLS
+		d := c.DrillStats[drill]
+		if drill != c.Drill && d.Shots == 0 {
+			continue
+		}
+
+		col := c.Colors[pln][0]
+		if drill != c.Drill {
+			col = c.Colors[pln][3]
+		}
+		hits, aim := "-", "-"
+		if d.Shots > 0 {
+			hits = fmt.Sprintf("%.0f%%", float64(d.Hits)/float64(d.Shots)*100)
+		}
+		if d.Returns > 0 {
+			aim = fmt.Sprintf("%.0f%%", d.Accuracy/float64(d.Returns)*100)
+		}
+		c.drawText(pln, x, y, col, "%-8s %d/%d hit %s, aim %s", drillNames[drill]+":", d.Hits, d.Shots, hits, aim)
+		y += fh
+	}
+}
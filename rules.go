@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qeedquan/go-media/math/ga/vec3"
+)
+
+// Handball variants, each one plays by its own rules and keeps
+// its own high scores for every challenge
+const (
+	VARIANT_CLASSIC = iota
+	VARIANT_SURVIVAL
+	VARIANT_TIME_ATTACK
+	VARIANT_SUDDEN_DEATH
+	NUM_VARIANTS
+)
+
+const (
+	// How much faster the ball comes off the paddle for every second
+	// of a survival game
+	SURVIVAL_PACE = 0.01
+
+	// How much the paddle shrinks with every hit in sudden death,
+	// and the smallest it gets
+	SUDDEN_DEATH_SHRINK = 0.95
+	SUDDEN_DEATH_MIN    = 0.3
+)
+
+// Rules are what sets a handball variant apart. A game starts with the
+// first serve and lasts until the rules say it is over, every paddle
+// hit is a point and the hooks add whatever else the variant does.
+type Rules struct {
+	Name string
+
+	// Misses a game lasts, 0 for as many as fit in the time
+	Lives int
+
+	// The game lasts ChallengeTime seconds, like every game
+	// of the timed challenge
+	Timed bool
+
+	// Called after the paddle sent the ball back and when the ball
+	// hits the wall of a seat without a paddle, either can be nil
+	Hit  func(c *Game, b *Ball)
+	Wall func(c *Game, b *Ball, seat int)
+
+	// Called when the last ball of a rally is gone, it reports
+	// whether the game is over, nil keeps going
+	Miss func(c *Game) bool
+
+	// Draws what the variant adds to the scores from line y down
+	// and returns the line after it, nil draws nothing
+	Draw func(c *Game, pln, y int) int
+}
+
+var variants = [NUM_VARIANTS]Rules{
+	VARIANT_CLASSIC: {
+		Name: "Classic", Lives: 1,
+		Wall: wallTargets, Miss: missLife,
+	},
+	VARIANT_SURVIVAL: {
+		Name: "Survival", Lives: 3,
+		Wall: wallTargets, Miss: missLife, Draw: drawLivesLeft,
+		Hit: func(c *Game, b *Ball) {
+			b.Vel = vec3.Scale(b.Vel, 1+SURVIVAL_PACE*float64(c.RunTime)/c.Config.TickRate)
+		},
+	},
+	VARIANT_TIME_ATTACK: {
+		Name: "Time attack", Timed: true,
+		Wall: wallTargets,
+	},
+	VARIANT_SUDDEN_DEATH: {
+		Name: "Sudden death", Lives: 1,
+		Wall: wallTargets, Miss: missLife,
+		Hit: func(c *Game, b *Ball) {
+			c.PaddleScale = math.Max(c.PaddleScale*SUDDEN_DEATH_SHRINK, SUDDEN_DEATH_MIN)
+		},
+	},
+}
+
+// rules are the rules of the handball game being played, nil in the
+// other modes.
+func (c *Game) rules() *Rules {
+	if c.Mode != HANDBALL {
+		return nil
+	}
+	return &variants[c.Variant]
+}
+
+// timed is whether the game runs against the clock, either because
+// of the variant or the challenge.
+func (c *Game) timed(r *Rules) bool {
+	return r.Timed || c.Challenge == CHALLENGE_TIMED
+}
+
+// highScore is the high score of the variant and challenge being played.
+func (c *Game) highScore() *int {
+	return &c.HighScores[c.Variant][c.Challenge]
+}
+
+// missLife takes a life, the game is over once they run out.
+func missLife(c *Game) bool {
+	c.Lives[0]--
+	return c.Lives[0] <= 0
+}
+
+// wallTargets scores the targets on the back wall.
+func wallTargets(c *Game, b *Ball, seat int) {
+	if seat == 1 {
+		c.hitTargets(b)
+	}
+}
+
+// drawLivesLeft shows the lives left of the game.
+func drawLivesLeft(c *Game, pln, y int) int {
+	c.drawText(pln, c.px(TEXT_MARGIN), y, c.Colors[pln][0], "Lives: %s", strings.Repeat("*", c.Lives[0]))
+	return y + c.FontHeight
+}
+
+// LoadHighScores reads the high scores of every variant and challenge.
+func (c *Game) LoadHighScores() error {
+	buf, err := os.ReadFile(c.HighScoreFile)
+	if err != nil {
+		return err
+	}
+
+	var m map[string]map[string]int
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.HighScoreFile, err)
+	}
+
+	for vname, cm := range m {
+		v := variantIndex(vname)
+		if v < 0 {
+			return fmt.Errorf("%s: unknown variant %q", c.HighScoreFile, vname)
+		}
+		for cname, score := range cm {
+			ch := challengeIndex(cname)
+			if ch < 0 {
+				return fmt.Errorf("%s: unknown challenge %q", c.HighScoreFile, cname)
+			}
+			c.HighScores[v][ch] = score
+		}
+	}
+	return nil
+}
+
+func (c *Game) SaveHighScores() error {
+	m := make(map[string]map[string]int)
+	for v := range c.HighScores {
+		cm := make(map[string]int)
+		for ch, score := range c.HighScores[v] {
+			cm[challengeNames[ch]] = score
+		}
+		m[variants[v].Name] = cm
+	}
+
+	buf, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.HighScoreFile), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.HighScoreFile, buf, 0644)
+}
+
+func variantIndex(name string) int {
+	for v := range variants {
+		if variants[v].Name == name {
+			return v
+		}
+	}
+	return -1
+}
+
+func challengeIndex(name string) int {
+	for ch, n := range challengeNames {
+		if n == name {
+			return ch
+		}
+	}
+	return -1
+}
+
+// setVariant switches to another variant, dropping the game in progress.
+func (c *Game) setVariant(variant int) {
+	c.Variant = variant
+	c.dropRun()
+}
+
+// dropRun throws away the handball game in progress without a score.
+func (c *Game) dropRun() {
+	c.Balls = c.Balls[:0]
+	c.BallInPlay = false
+	c.BallWaitingFor = 0
+	c.Score[0] = 0
+	c.FinalScore = -1
+	c.GotHighScore = false
+	c.resetRun()
+}
+
+// resetRun puts the state of the game back to before the first serve.
+func (c *Game) resetRun() {
+	c.Running = false
+	c.RunTime = 0
+	c.TimeLeft = int(c.ChallengeTime * c.Config.TickRate)
+	c.PaddleScale = 1
+	if r := c.rules(); r != nil {
+		c.Lives[0] = r.Lives
+	}
+}
+
+// startRun starts a handball game with the first serve.
+func (c *Game) startRun() {
+	r := c.rules()
+	if r == nil || c.Running {
+		return
+	}
+	c.resetRun()
+	c.Running = true
+	c.Score[0] = 0
+	c.GotHighScore = false
+}
+
+// moveRun moves a handball game along by a tick, a timed game
+// that runs out of time ends with the balls still in play.
+func (c *Game) moveRun() {
+	r := c.rules()
+	if r == nil || !c.Running {
+		return
+	}
+	c.RunTime++
+	if !c.timed(r) {
+		return
+	}
+	if c.TimeLeft--; c.TimeLeft <= 0 {
+		c.playSound("score")
+		c.endRun()
+		c.Balls = c.Balls[:0]
+		c.endRally()
+	}
+}
+
+// hitRun scores a paddle hit in handball.
+func (c *Game) hitRun(b *Ball) {
+	r := c.rules()
+	if r == nil {
+		return
+	}
+	c.addScore(1)
+	if r.Hit != nil {
+		r.Hit(c, b)
+	}
+}
+
+// wallRun is called when a ball bounces off the wall of a seat
+// without a paddle in handball.
+func (c *Game) wallRun(b *Ball, seat int) {
+	r := c.rules()
+	if r == nil || r.Wall == nil {
+		return
+	}
+	r.Wall(c, b, seat)
+}
+
+// missRun is called when the last ball of a handball rally is gone.
+func (c *Game) missRun() {
+	r := c.rules()
+	if r == nil {
+		return
+	}
+	c.Combo = 0
+	c.BallWaitingFor = 0
+	if c.Running && r.Miss != nil && r.Miss(c) {
+		c.endRun()
+	}
+}
+
+// endRun finishes a handball game with the final score.
+func (c *Game) endRun() {
+	c.FinalScore = c.Score[0]
+	c.GotHighScore = c.FinalScore >= *c.highScore()
+	c.Score[0] = 0
+	c.resetRun()
+	if c.GotHighScore {
+		ek(c.SaveHighScores())
+	}
+}
+
+// addScore gives points in handball and keeps the high score.
+func (c *Game) addScore(n int) {
+	c.Score[0] += n
+	c.FinalScore = c.Score[0]
+
+	if h := c.highScore(); c.Score[0] > *h {
+		*h = c.Score[0]
+	}
+}
+
+// drawRun shows the scores of a handball game: the score and high
+// score, the variant and challenge being played, the time left and
+// whatever the variant adds. It reports whether it drew anything.
+func (c *Game) drawRun(pln int) bool {
+	r := c.rules()
+	if r == nil {
+		return false
+	}
+
+	fh := c.FontHeight
+	x := c.px(TEXT_MARGIN)
+	col := c.Colors[pln][0]
+	c.drawText(pln, x, fh, col, "Score: %d", c.Score[0])
+	c.drawText(pln, x, fh*2, col, "High:  %d", *c.highScore())
+	c.drawText(pln, x, fh*3, c.Colors[pln][3], "%s, %s", r.Name, challengeNames[c.Challenge])
+	y := fh * 4
+	if c.timed(r) {
+		c.drawText(pln, x, y, col, "Time:  %.0f", math.Ceil(float64(c.TimeLeft)/c.Config.TickRate))
+		y += fh
+	}
+	if r.Draw != nil {
+		y = r.Draw(c, pln, y)
+	}
+	if c.Combo > 1 {
+		c.drawText(pln, x, y, c.Colors[pln][2], "Combo: x%d", c.Combo)
+	}
+	return true
+}
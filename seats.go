@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/qeedquan/go-media/math/ga"
+)
+
+// A seat is a place at the table, one for each paddle. Seats 1 and 2 guard
+// the -Z and +Z ends like they always have, seats 3 and 4 guard the -X and
+// +X side walls in four player mode or play in front of seats 1 and 2 in
+// doubles.
+const MAX_SEATS = 4
+
+// Who is playing in a seat
+const (
+	SEAT_HUMAN = iota
+	SEAT_AI
+	SEAT_REMOTE
+	NUM_SEAT_TYPES
+)
+
+var seatTypeNames = [NUM_SEAT_TYPES]string{"human", "computer", "network"}
+
+// Colors of the players on the side walls, players 1 and 2 use Colors
+var sideColors = [2]color.RGBA{
+	{255, 255, 0, 255},
+	{255, 0, 255, 255},
+}
+
+// seats is how many seats are played in the current mode.
+func (c *Game) seats() int {
+	switch c.Mode {
+	case HANDBALL, PRACTICE:
+		return 1
+	case FOUR_PLAYERS, DOUBLES:
+		return MAX_SEATS
+	}
+	return 2
+}
+
+// seatType is who is playing in a seat in the current mode.
+func (c *Game) seatType(seat int) int {
+	switch c.Mode {
+	case HANDBALL, TWO_PLAYERS, PRACTICE:
+		return SEAT_HUMAN
+	case ONE_PLAYER:
+		if seat == 1 {
+			return SEAT_AI
+		}
+		return SEAT_HUMAN
+	}
+	return c.Config.Seats[seat]
+}
+
+// guarded reports whether a paddle guards the end of a seat,
+// otherwise the ball bounces off of it like any other wall.
+func (c *Game) guarded(seat int) bool {
+	if seat >= c.seats() {
+		return false
+	}
+	return c.Mode != FOUR_PLAYERS || c.Lives[seat] > 0
+}
+
+// team is who a seat plays with, every seat is on its own unless
+// teams are on, then the ends play against the side walls. In
+// doubles the front and back player of an end are a team.
+func (c *Game) team(seat int) int {
+	switch {
+	case c.Mode == DOUBLES:
+		return seat % 2
+	case c.Mode == FOUR_PLAYERS && c.Teams:
+		return seat / 2
+	}
+	return seat
+}
+
+// end is which end of the arena a seat plays from, the
+// front players in doubles share the end behind them.
+func (c *Game) end(seat int) int {
+	if c.front(seat) {
+		return seat - 2
+	}
+	return seat
+}
+
+// front reports whether a seat plays the front paddle in doubles.
+func (c *Game) front(seat int) bool {
+	return c.Mode == DOUBLES && seat >= 2
+}
+
+// paddleZ is how far along the Z axis of a seat its paddle is.
+func (c *Game) paddleZ(seat int) float64 {
+	z := -c.seatSize(seat).Z
+	if c.front(seat) {
+		z += c.DoublesGap
+	}
+	return z
+}
+
+// assignSeats gives each human seat a viewport, if nobody is
+// playing the first viewport watches from the first seat.
+func (c *Game) assignSeats() {
+	c.Viewports = 0
+	c.ViewSeat = [2]int{0, 1}
+	for seat := 0; seat < c.seats(); seat++ {
+		if c.seatType(seat) == SEAT_HUMAN && c.Viewports < len(c.ViewSeat) {
+			c.ViewSeat[c.Viewports] = seat
+			c.Viewports++
+		}
+	}
+	if c.Link != nil && !c.Link.Host {
+		c.Viewports = 1
+		c.ViewSeat[0] = c.Config.JoinSeat
+	}
+	if c.Viewports == 0 {
+		c.Viewports = 1
+	}
+}
+
+// viewOf returns the viewport of a seat, -1 if it does not have one.
+func (c *Game) viewOf(seat int) int {
+	for pln := 0; pln < c.Viewports; pln++ {
+		if c.ViewSeat[pln] == seat {
+			return pln
+		}
+	}
+	return -1
+}
+
+// seatToWorld turns a point or direction seen from behind the paddle
+// of a seat, where the paddle is at -Z, into the arena.
+func (c *Game) seatToWorld(seat int, p ga.Vec3d) ga.Vec3d {
+	switch c.end(seat) {
+	case 1:
+		return ga.Vec3d{-p.X, p.Y, -p.Z}
+	case 2:
+		return ga.Vec3d{p.Z, p.Y, -p.X}
+	case 3:
+		return ga.Vec3d{-p.Z, p.Y, p.X}
+	}
+	return p
+}
+
+// worldToSeat is the opposite of seatToWorld.
+func (c *Game) worldToSeat(seat int, p ga.Vec3d) ga.Vec3d {
+	switch c.end(seat) {
+	case 1:
+		return ga.Vec3d{-p.X, p.Y, -p.Z}
+	case 2:
+		return ga.Vec3d{-p.Z, p.Y, p.X}
+	case 3:
+		return ga.Vec3d{p.Z, p.Y, -p.X}
+	}
+	return p
+}
+
+// seatSize is the size of the arena seen from a seat.
+func (c *Game) seatSize(seat int) ga.Vec3d {
+	a := c.Arena
+	if c.end(seat) >= 2 {
+		return ga.Vec3d{a.Z, a.Y, a.X}
+	}
+	return a
+}
+
+// toView turns a point in the arena around to the side of the table
+// the player of a viewport is on.
+func (c *Game) toView(pln int, p ga.Vec3d) ga.Vec3d {
+	return c.worldToSeat(c.ViewSeat[pln], p)
+}
+
+// viewPlane is the plane to draw a ring flat against a surface
+// with normal n in the arena in.
+func (c *Game) viewPlane(pln int, n ga.Vec3d) int {
+	n = c.toView(pln, n)
+	switch {
+	case math.Abs(n.X) >= math.Abs(n.Y) && math.Abs(n.X) >= math.Abs(n.Z):
+		return PLANE_YZ
+	case math.Abs(n.Y) >= math.Abs(n.Z):
+		return PLANE_XZ
+	}
+	return PLANE_XY
+}
+
+// seatColor is the color of a seat in a viewport, dark is used
+// for everyone but the player of the viewport. The front players
+// in doubles have colors of their own.
+func (c *Game) seatColor(pln, seat int, dark bool) color.RGBA {
+	if c.front(seat) {
+		if dark {
+			return c.Colors[pln][c.end(seat)+8]
+		}
+		return c.Colors[pln][c.end(seat)+6]
+	}
+	if seat < 2 {
+		if dark {
+			return c.Colors[pln][seat+3]
+		}
+		return c.Colors[pln][seat]
+	}
+	col := sideColors[seat-2]
+	if dark {
+		return shade(col, 0.55)
+	}
+	return col
+}
+
+// paddleCorners are the corners of the paddle of a seat in a viewport.
+func (c *Game) paddleCorners(pln, seat int) [4]ga.Vec3d {
+	p := c.Player[seat]
+	s := c.paddleSize(seat)
+	z := c.paddleZ(seat)
+	pts := [4]ga.Vec3d{
+		{p.X - s.X, p.Y - s.Y, z},
+		{p.X + s.X, p.Y - s.Y, z},
+		{p.X + s.X, p.Y + s.Y, z},
+		{p.X - s.X, p.Y + s.Y, z},
+	}
+	for i := range pts {
+		pts[i] = c.toView(pln, c.seatToWorld(seat, pts[i]))
+	}
+	return pts
+}
+
+// drawPaddle draws the paddle of a seat, with a cross
+// over it when it just hit the ball.
+func (c *Game) drawPaddle(pln, seat int, col color.RGBA) {
+	pts := c.paddleCorners(pln, seat)
+	c.drawSolidPaddle(pln, pts, col)
+	for i := range pts {
+		c.drawLine(pln, pts[i], pts[(i+1)%len(pts)], col)
+	}
+
+	// Draw "paddle hit the ball" effect
+	if c.Shimmering[seat] != 0 {
+		c.drawLine(pln, pts[0], pts[2], col)
+		c.drawLine(pln, pts[1], pts[3], col)
+	}
+}
+
+// onPaddle reports whether a ball at p, seen from a seat, touches its paddle.
+func (c *Game) onPaddle(seat int, p ga.Vec3d) bool {
+	pp := c.Player[seat]
+	ps := c.paddleSize(seat)
+	return p.X+c.BallSize >= pp.X-ps.X &&
+		p.X-c.BallSize <= pp.X+ps.X &&
+		p.Y+c.BallSize >= pp.Y-ps.Y &&
+		p.Y-c.BallSize <= pp.Y+ps.Y
+}
+
+// moveSeats moves the paddles of the computer players toward the
+// ball that gets to them first and serves for them.
+func (c *Game) moveSeats() {
+	for seat := 0; seat < c.seats(); seat++ {
+		if c.seatType(seat) == SEAT_HUMAN || !c.guarded(seat) {
+			continue
+		}
+
+		// Remove their "ball hit paddle" effect
+		if c.Shimmering[seat] != 0 {
+			c.Shimmering[seat]--
+		}
+		if c.seatType(seat) != SEAT_AI {
+			continue
+		}
+
+		// Move paddle to follow the ball that gets to it first
+		p := &c.Player[seat]
+		if b := c.threat(seat); b != nil {
+			v := c.worldToSeat(seat, b.Pos)
+
+			// In doubles the back player leaves the ball to the front
+			// player if they have it covered and guards the other side
+			if mate := seat + 2; c.Mode == DOUBLES && !c.front(seat) && c.onPaddle(mate, v) {
+				m := c.Player[mate]
+				v.X, v.Y = -m.X, -m.Y
+			}
+
+			if v.X < p.X {
+				p.X -= (c.ComputerSpeed + float64(rand.Intn(2)))
+			} else if v.X > p.X {
+				p.X += (c.ComputerSpeed + float64(rand.Intn(2)))
+			}
+
+			if v.Y < p.Y {
+				p.Y -= (c.ComputerSpeed + float64(rand.Intn(2)))
+			} else if v.Y > p.Y {
+				p.Y += (c.ComputerSpeed + float64(rand.Intn(2)))
+			}
+		}
+
+		// Launch ball if it's our serve
+		if !c.BallInPlay && c.BallWaitingFor == seat && rand.Intn(10) < 1 {
+			c.putBallInPlay(seat)
+		}
+	}
+}
+
+// loseLife takes a life from a seat that missed the ball in four player
+// mode, when only one player or team is left they win the game.
+func (c *Game) loseLife(seat int) {
+	c.Lives[seat]--
+
+	// The next serve is theirs, or the next player still in the game
+	for i := 0; i < MAX_SEATS; i++ {
+		if s := (seat + i) % MAX_SEATS; c.Lives[s] > 0 {
+			c.BallWaitingFor = s
+			break
+		}
+	}
+
+	winner := -1
+	for s := 0; s < MAX_SEATS; s++ {
+		if c.Lives[s] == 0 {
+			continue
+		}
+		if winner >= 0 && c.team(winner) != c.team(s) {
+			return
+		}
+		winner = s
+	}
+	if winner < 0 {
+		return
+	}
+
+	for s := 0; s < MAX_SEATS; s++ {
+		if c.team(s) == c.team(winner) {
+			c.Score[s]++
+		}
+	}
+	c.Winner = winner
+}
+
+// newGame gives every seat their lives back after a game was won.
+func (c *Game) newGame() {
+	for i := range c.Lives {
+		c.Lives[i] = c.Config.Lives
+	}
+	c.Winner = -1
+}
+
+// drawLives shows the lives and games won of every seat in four player mode.
+func (c *Game) drawLives(pln int) {
+	fh := c.FontHeight
+	x := c.px(TEXT_MARGIN)
+	for i := 0; i < MAX_SEATS; i++ {
+		// Your own seat first
+		seat := (c.ViewSeat[pln] + i) % MAX_SEATS
+		lives := "out"
+		if c.Lives[seat] > 0 {
+			lives = strings.Repeat("*", c.Lives[seat])
+		}
+		name := fmt.Sprintf("Player %d", seat+1)
+		if c.Teams {
+			name += fmt.Sprintf(" (team %d)", c.team(seat)+1)
+		}
+		col := c.seatColor(pln, seat, seat != c.ViewSeat[pln])
+		c.drawText(pln, x, fh*(i+1), col, "%s: %-5s %d", name, lives, c.Score[seat])
+	}
+
+	if c.Winner >= 0 {
+		text := fmt.Sprintf("Player %d wins!", c.Winner+1)
+		if c.Teams {
+			text = fmt.Sprintf("Team %d wins!", c.team(c.Winner)+1)
+		}
+		x := c.Bound[pln].Dx() / 2
+		y := c.Bound[pln].Dy()/2 - fh*2
+		c.drawTextAligned(pln, ALIGN_CENTER, x, y, c.seatColor(pln, c.Winner, false), "%s", text)
+	}
+}
+
+// seatsFlag parses the players of the seats in four player mode
+// from a comma separated list of seat types.
+type seatsFlag [MAX_SEATS]int
+
+func (s *seatsFlag) String() string {
+	var names []string
+	for _, t := range s {
+		if 0 <= t && t < NUM_SEAT_TYPES {
+			names = append(names, seatTypeNames[t])
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (s *seatsFlag) Set(value string) error {
+	names := strings.Split(value, ",")
+	if len(names) != MAX_SEATS {
+		return fmt.Errorf("need %d seats, got %d", MAX_SEATS, len(names))
+	}
+loop:
+	for i, name := range names {
+		for t, tn := range seatTypeNames {
+			if strings.TrimSpace(name) == tn {
+				s[i] = t
+				continue loop
+			}
+		}
+		return fmt.Errorf("unknown seat type %q (%s)", name, strings.Join(seatTypeNames[:], ", "))
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/math/ga/vec3"
+	"github.com/qeedquan/go-media/sdl"
+)
+
+const (
+	// Lines longer than this are split so they sort well against faces
+	SOLID_SEGMENT = 50
+
+	// Spacing of the floor grid lines
+	GRID_SPACING = 25
+
+	// Opacity of the paddles in solid mode
+	PADDLE_ALPHA = 128
+
+	// Light that is not affected by the angle of a face
+	AMBIENT = 0.35
+)
+
+// A Prim is a face or a line in camera space waiting to be sorted
+// back to front and drawn by the painter's algorithm.
+type Prim struct {
+	Points [4]ga.Vec3d
+	Count  int
+	Color  color.RGBA
+	Depth  float64
+}
+
+// Light shines from above and behind the camera.
+var lightDir = vec3.Normalize(ga.Vec3d{-0.3, -0.6, -1})
+
+// drawSolidLine queues a line to be sorted with the faces,
+// long lines are cut into pieces so each piece sorts on its own.
+func (c *Game) drawSolidLine(pln int, p1, p2 ga.Vec3d, col color.RGBA) {
+	n := int(math.Ceil(vec3.Len(vec3.Sub(p2, p1)) / SOLID_SEGMENT))
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		a := vec3.Lerp(float64(i)/float64(n), p1, p2)
+		b := vec3.Lerp(float64(i+1)/float64(n), p1, p2)
+		c.queuePrim(pln, col, a, b)
+	}
+}
+
+// drawFace queues a flat shaded polygon of 3 or 4 points,
+// it is only drawn in solid mode.
+func (c *Game) drawFace(pln int, col color.RGBA, pts ...ga.Vec3d) {
+	if !c.Solid[pln] {
+		return
+	}
+	c.queuePrim(pln, col, pts...)
+}
+
+func (c *Game) queuePrim(pln int, col color.RGBA, pts ...ga.Vec3d) {
+	var p Prim
+	for i, pt := range pts {
+		p.Points[i] = c.transform(pln, pt)
+
+		// Inside of the distance clip plane
+		if p.Points[i].Z <= -c.Distance {
+			return
+		}
+		p.Depth += p.Points[i].Z
+	}
+	p.Count = len(pts)
+	p.Depth /= float64(p.Count)
+	p.Color = col
+	if p.Count > 2 {
+		p.Color = shade(col, c.faceLight(p.Points[:p.Count]))
+	}
+	c.Prims = append(c.Prims, p)
+}
+
+// faceLight is how brightly lit a face is, faces seen from
+// either side are lit the same so winding does not matter.
+func (c *Game) faceLight(pts []ga.Vec3d) float64 {
+	n := vec3.Cross(vec3.Sub(pts[1], pts[0]), vec3.Sub(pts[2], pts[0]))
+	if vec3.Len(n) == 0 {
+		return 1
+	}
+	d := math.Abs(vec3.Dot(vec3.Normalize(n), lightDir))
+	return AMBIENT + (1-AMBIENT)*d
+}
+
+func shade(col color.RGBA, light float64) color.RGBA {
+	return color.RGBA{
+		uint8(float64(col.R) * light),
+		uint8(float64(col.G) * light),
+		uint8(float64(col.B) * light),
+		col.A,
+	}
+}
+
+// flushSolid sorts everything queued in solid mode from back to front
+// and turns it into triangles for each eye, drawn before the HUD lines.
+func (c *Game) flushSolid(pln int) {
+	prims := c.Prims
+	if len(prims) == 0 {
+		return
+	}
+
+	sort.SliceStable(prims, func(i, j int) bool {
+		return prims[i].Depth > prims[j].Depth
+	})
+
+	width := math.Max(1, c.lineWidth())
+	mode := c.Glasses[pln]
+	for eye := 0; eye < c.eyes(pln); eye++ {
+		set := &c.Lines[0]
+		if mode == STEREO_INTERLACED && eye == 1 {
+			set = &c.Lines[1]
+		}
+
+		vs, is := set.Vertices, set.Indices
+		for i := range prims {
+			p := &prims[i]
+			col := c.eyeColor(pln, eye, p.Color)
+			if p.Count == 2 {
+				s1 := c.project(pln, eye, p.Points[0])
+				s2 := c.project(pln, eye, p.Points[1])
+				vs, is = c.appendLineQuads(vs, is, col, s1, s2, width)
+				continue
+			}
+
+			// Fan out the polygon into triangles
+			o := int32(len(vs))
+			for j := 0; j < p.Count; j++ {
+				s := c.project(pln, eye, p.Points[j])
+				vs = append(vs, sdl.Vertex{
+					Position: sdl.FPoint{float32(s.X), float32(s.Y)},
+					Color:    sdl.Color{col.R, col.G, col.B, col.A},
+				})
+			}
+			for j := int32(1); j < int32(p.Count)-1; j++ {
+				is = append(is, o, o+j, o+j+1)
+			}
+		}
+		set.Vertices, set.Indices = vs, is
+	}
+	c.Prims = prims[:0]
+}
+
+// eyeColor tints a solid color with the filter of an anaglyph eye,
+// keeping its brightness so the shading still shows through.
+func (c *Game) eyeColor(pln, eye int, col color.RGBA) color.RGBA {
+	mode := c.Glasses[pln]
+	if !isAnaglyph(mode) {
+		return col
+	}
+	tint := stereoModes[mode].Left
+	if eye == 1 {
+		tint = stereoModes[mode].Right
+	}
+	l := (float64(col.R) + float64(col.G) + float64(col.B)) / (3 * 255)
+	return shade(color.RGBA{tint.R, tint.G, tint.B, col.A}, l)
+}
+
+// drawFloor draws a grid on the floor of the arena in solid mode.
+func (c *Game) drawFloor(pln int) {
+	if !c.Solid[pln] {
+		return
+	}
+	c.drawGrid(pln, GRID_SPACING, c.Colors[pln][5])
+}
+
+// drawGrid draws lines on the floor of the arena every spacing units,
+// in solid mode they are sorted in with the faces.
+func (c *Game) drawGrid(pln int, spacing float64, col color.RGBA) {
+	x := c.Arena.X
+	y := c.Arena.Y
+	z := c.Arena.Z
+	line := func(p, q ga.Vec3d) {
+		p, q = c.toView(pln, p), c.toView(pln, q)
+		if c.Solid[pln] {
+			c.drawSolidLine(pln, p, q, col)
+		} else {
+			c.drawLine(pln, p, q, col)
+		}
+	}
+
+	// Lay the grid out from the center so it stays symmetric
+	for gx := 0.0; gx < x; gx += spacing {
+		line(ga.Vec3d{gx, y, -z}, ga.Vec3d{gx, y, z})
+		if gx != 0 {
+			line(ga.Vec3d{-gx, y, -z}, ga.Vec3d{-gx, y, z})
+		}
+	}
+	for gz := 0.0; gz < z; gz += spacing {
+		line(ga.Vec3d{-x, y, gz}, ga.Vec3d{x, y, gz})
+		if gz != 0 {
+			line(ga.Vec3d{-x, y, -gz}, ga.Vec3d{x, y, -gz})
+		}
+	}
+}
+
+// drawSolidPaddle fills in a paddle with a translucent face.
+func (c *Game) drawSolidPaddle(pln int, pts [4]ga.Vec3d, col color.RGBA) {
+	col.A = PADDLE_ALPHA
+	c.drawFace(pln, col, pts[0], pts[1], pts[2], pts[3])
+}
+
+// drawSolidBall fills in the 8 faces of the octahedron ball.
+func (c *Game) drawSolidBall(pln int, x, y, z, s float64, col color.RGBA) {
+	ring := [4]ga.Vec3d{
+		{x - s, y, z - s},
+		{x + s, y, z - s},
+		{x + s, y, z + s},
+		{x - s, y, z + s},
+	}
+	tips := [2]ga.Vec3d{
+		{x, y - s, z},
+		{x, y + s, z},
+	}
+	for _, tip := range tips {
+		for i := range ring {
+			c.drawFace(pln, col, ring[i], ring[(i+1)%len(ring)], tip)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/qeedquan/go-media/math/ga"
+	"github.com/qeedquan/go-media/sdl"
+)
+
+const (
+	STEREO_OFF = iota
+	STEREO_RED_CYAN
+	STEREO_GREEN_MAGENTA
+	STEREO_AMBER_BLUE
+	STEREO_SIDE_BY_SIDE
+	STEREO_TOP_BOTTOM
+	STEREO_INTERLACED
+	STEREO_CROSS_EYE
+	NUM_STEREO
+)
+
+// The anaglyph modes draw each eye in the color of its filter,
+// the other modes keep the colors of the lines.
+var stereoModes = [NUM_STEREO]struct {
+	Name  string
+	Left  color.RGBA
+	Right color.RGBA
+}{
+	{Name: "Off"},
+	{"Red/Cyan", color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 255, 255}},
+	{"Green/Magenta", color.RGBA{0, 255, 0, 255}, color.RGBA{255, 0, 255, 255}},
+	{"Amber/Blue", color.RGBA{255, 191, 0, 255}, color.RGBA{0, 0, 255, 255}},
+	{Name: "Side by side"},
+	{Name: "Top/Bottom"},
+	{Name: "Interlaced"},
+	{Name: "Cross-eye"},
+}
+
+func isAnaglyph(mode int) bool {
+	return STEREO_RED_CYAN <= mode && mode <= STEREO_AMBER_BLUE
+}
+
+// eyes is how many times the scene is drawn for a player.
+func (c *Game) eyes(pln int) int {
+	if c.Glasses[pln] == STEREO_OFF {
+		return 1
+	}
+	return 2
+}
+
+// eyeView returns the part of the viewport an eye is drawn into
+// and how much to scale its image horizontally and vertically.
+func (c *Game) eyeView(pln, eye int) (image.Rectangle, float64, float64) {
+	r := c.Bound[pln]
+	s := c.Scale[pln]
+	mx := (r.Min.X + r.Max.X) / 2
+	my := (r.Min.Y + r.Max.Y) / 2
+
+	switch c.Glasses[pln] {
+	case STEREO_SIDE_BY_SIDE:
+		// Squeezed to half width, the display stretches it back
+		if eye == 0 {
+			r.Max.X = mx
+		} else {
+			r.Min.X = mx
+		}
+		return r, s / 2, s
+
+	case STEREO_TOP_BOTTOM:
+		if eye == 0 {
+			r.Max.Y = my
+		} else {
+			r.Min.Y = my
+		}
+		return r, s, s / 2
+
+	case STEREO_CROSS_EYE:
+		// The left eye looks at the right image
+		if eye == 0 {
+			r.Min.X = mx
+		} else {
+			r.Max.X = mx
+		}
+		s = float64(r.Dx()) / float64(c.Config.Width)
+		if sy := float64(r.Dy()) / float64(c.Config.Height); sy < s {
+			s = sy
+		}
+		return r, s, s
+	}
+	return r, s, s
+}
+
+// project converts a point in camera space into the window for an eye,
+// each eye looks from its own position through an off-axis frustum so
+// that points at the convergence distance line up for both eyes.
+func (c *Game) project(pln, eye int, p ga.Vec3d) ga.Vec2d {
+	sep := 0.0
+	if c.eyes(pln) == 2 {
+		sep = c.IPD / 2
+		if eye == 0 {
+			sep = -sep
+		}
+	}
+
+	r, sx, sy := c.eyeView(pln, eye)
+	d := p.Z + c.Distance
+	s := ga.Vec2d{
+		((p.X-sep)/d + sep/c.Convergence) * c.Aspect * sx,
+		p.Y / d * c.Aspect * sy,
+	}
+
+	// Transpose (0, 0) origin to center of the view
+	s.X += float64(r.Min.X) + float64(r.Dx())/2
+	s.Y += float64(r.Min.Y) + float64(r.Dy())/2
+	return s
+}
+
+func (c *Game) queueEyeLine(pln, eye int, col color.RGBA, s1, s2 ga.Vec2d) {
+	mode := c.Glasses[pln]
+	if isAnaglyph(mode) {
+		col = stereoModes[mode].Left
+		if eye == 1 {
+			col = stereoModes[mode].Right
+		}
+	}
+
+	// The right eye of interlaced stereo is drawn separately
+	// and then merged into the odd rows
+	if mode == STEREO_INTERLACED && eye == 1 {
+		c.Lines[1].queue(col, s1, s2)
+		return
+	}
+	c.queueLine(col, s1, s2)
+}
+
+// beginStereo sets up line drawing for the stereo mode of a player,
+// anaglyph eyes are added together so overlapping lines mix colors.
+func (c *Game) beginStereo(pln int) {
+	c.ActiveView = pln
+	c.LineBlend = sdl.BLENDMODE_NONE
+	if isAnaglyph(c.Glasses[pln]) {
+		c.LineBlend = sdl.BLENDMODE_ADD
+	}
+}
+
+func (c *Game) endStereo() {
+	c.LineBlend = sdl.BLENDMODE_NONE
+}
+
+// flushInterlaced draws the right eye into a texture and copies
+// its odd rows over the left eye already drawn in the window.
+func (c *Game) flushInterlaced() {
+	re := c.Renderer
+	if c.StereoTarget == nil || c.StereoSize != image.Pt(c.Width, c.Height) {
+		if c.StereoTarget != nil {
+			c.StereoTarget.Destroy()
+		}
+		texture, err := re.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_TARGET, c.Width, c.Height)
+		ck(err)
+		texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+		c.StereoTarget = texture
+		c.StereoSize = image.Pt(c.Width, c.Height)
+	}
+
+	re.SetTarget(c.StereoTarget)
+	re.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+	re.SetDrawColor(color.RGBA{0, 0, 0, 0})
+	re.Clear()
+	c.drawLineSet(&c.Lines[1])
+	re.SetTarget(nil)
+
+	b := c.Bound[c.ActiveView]
+	rows := c.Rows[:0]
+	for y := b.Min.Y + 1; y < b.Max.Y; y += 2 {
+		rows = append(rows, sdl.Rect{int32(b.Min.X), int32(y), int32(b.Dx()), 1})
+	}
+	c.Rows = rows
+
+	// Clear the rows of the left eye that belong to the right eye
+	re.SetClipRect(&sdl.Rect{int32(b.Min.X), int32(b.Min.Y), int32(b.Dx()), int32(b.Dy())})
+	re.SetDrawColor(color.RGBA{0, 0, 0, 255})
+	re.FillRects(rows)
+	for i := range rows {
+		re.Copy(c.StereoTarget, &rows[i], &rows[i])
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/qeedquan/go-media/math/ga"
+)
+
+// Handball challenges, each one keeps its own high scores
+const (
+	// Every hit is a point like in the original
+	CHALLENGE_CLASSIC = iota
+	// Targets on the back wall give bonus points
+	CHALLENGE_TARGETS
+	// Like targets but they move around the wall
+	CHALLENGE_MOVING
+	// Moving targets against the clock, with the misses the variant allows
+	CHALLENGE_TIMED
+	NUM_CHALLENGES
+)
+
+var challengeNames = [NUM_CHALLENGES]string{"Classic", "Targets", "Moving", "Timed"}
+
+const (
+	// How long a target stays lit after it was hit
+	TARGET_LIGHT = 40
+
+	// Most a combo multiplies the points of a target by
+	MAX_COMBO = 5
+)
+
+var (
+	targetColor = color.RGBA{255, 255, 0, 255}
+	litColor    = color.RGBA{255, 255, 255, 255}
+)
+
+// A Target is a zone on the back wall in handball that is worth
+// bonus points when the ball hits it, it stays lit for a bit after.
+type Target struct {
+	Pos ga.Vec2d
+	Vel ga.Vec2d
+	Lit int
+}
+
+// targetBounds is how far from the middle of the back wall a target can go.
+func (c *Game) targetBounds() ga.Vec2d {
+	s := c.TargetSize
+	return ga.Vec2d{math.Max(0, c.Arena.X-s), math.Max(0, c.Arena.Y-s)}
+}
+
+func (c *Game) randomTargetPos() ga.Vec2d {
+	b := c.targetBounds()
+	return ga.Vec2d{(rand.Float64()*2 - 1) * b.X, (rand.Float64()*2 - 1) * b.Y}
+}
+
+// setChallenge switches to another challenge, dropping the game in progress.
+func (c *Game) setChallenge(challenge int) {
+	c.Challenge = challenge
+	c.dropRun()
+	c.placeTargets()
+}
+
+// placeTargets scatters the targets of the challenge over the back wall.
+func (c *Game) placeTargets() {
+	c.Targets = c.Targets[:0]
+	c.Combo = 0
+	if c.Challenge == CHALLENGE_CLASSIC {
+		return
+	}
+	for i := 0; i < c.TargetCount; i++ {
+		t := Target{Pos: c.randomTargetPos()}
+		if c.Challenge != CHALLENGE_TARGETS {
+			a := rand.Float64() * 2 * math.Pi
+			t.Vel = ga.Vec2d{math.Cos(a) * c.TargetSpeed, math.Sin(a) * c.TargetSpeed}
+		}
+		c.Targets = append(c.Targets, t)
+	}
+}
+
+// moveTargets moves the targets around the back wall, still targets
+// go somewhere else once they go out.
+func (c *Game) moveTargets() {
+	if c.Mode != HANDBALL {
+		return
+	}
+
+	b := c.targetBounds()
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.Lit > 0 {
+			if t.Lit--; t.Lit == 0 && t.Vel == (ga.Vec2d{}) {
+				t.Pos = c.randomTargetPos()
+			}
+		}
+
+		t.Pos.X += t.Vel.X
+		t.Pos.Y += t.Vel.Y
+		if math.Abs(t.Pos.X) > b.X {
+			t.Pos.X = math.Copysign(b.X, t.Pos.X)
+			t.Vel.X = -t.Vel.X
+		}
+		if math.Abs(t.Pos.Y) > b.Y {
+			t.Pos.Y = math.Copysign(b.Y, t.Pos.Y)
+			t.Vel.Y = -t.Vel.Y
+		}
+	}
+}
+
+// hitTargets scores a ball hitting the back wall in handball, every
+// target hit in a row without missing one counts for more.
+func (c *Game) hitTargets(b *Ball) {
+	if len(c.Targets) == 0 {
+		return
+	}
+
+	hit := false
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.Lit > 0 || math.Hypot(b.Pos.X-t.Pos.X, b.Pos.Y-t.Pos.Y) > c.TargetSize {
+			continue
+		}
+		hit = true
+		t.Lit = TARGET_LIGHT
+		if c.Combo < MAX_COMBO {
+			c.Combo++
+		}
+		c.addScore(c.TargetPoints * c.Combo)
+		c.addSparks(ga.Vec3d{t.Pos.X, t.Pos.Y, c.Arena.Z}, ga.Vec3d{0, 0, -1})
+	}
+
+	if hit {
+		c.playSound("score")
+	} else {
+		c.Combo = 0
+	}
+}
+
+// drawTargets draws the targets on the back wall, filled in while lit.
+func (c *Game) drawTargets(pln int) {
+	if c.Mode != HANDBALL {
+		return
+	}
+	for _, t := range c.Targets {
+		col, fill := targetColor, color.RGBA{}
+		if t.Lit > 0 {
+			col, fill = litColor, targetColor
+		}
+		p := c.toView(pln, ga.Vec3d{t.Pos.X, t.Pos.Y, c.Arena.Z})
+		c.drawRing(pln, PLANE_XY, p, c.TargetSize, col, fill)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+
+	"github.com/qeedquan/go-media/sdl"
+	"github.com/qeedquan/go-media/sdl/sdlimage/sdlcolor"
+)
+
+const (
+	ALIGN_LEFT = iota
+	ALIGN_CENTER
+	ALIGN_RIGHT
+)
+
+const (
+	// Range of characters rendered into the glyph atlas
+	FIRST_GLYPH = ' '
+	LAST_GLYPH  = '~'
+
+	// Glyphs per row in the atlas
+	ATLAS_COLUMNS = 16
+)
+
+// An Atlas holds every glyph of the HUD font in one texture, so drawing
+// text only copies small rectangles out of it instead of uploading pixels.
+type Atlas struct {
+	Texture *sdl.Texture
+	Glyphs  map[rune]sdl.Rect
+}
+
+// A TextQuad is one glyph queued to be drawn when the text is flushed.
+type TextQuad struct {
+	Color color.RGBA
+	Src   sdl.Rect
+	Dst   sdl.Rect
+}
+
+// buildAtlas renders every glyph of the font once and packs them in a grid.
+func (c *Game) buildAtlas() {
+	font := c.Font
+	if c.Atlas.Texture != nil {
+		c.Atlas.Texture.Destroy()
+	}
+
+	// The cell size is the size of the largest glyph
+	cw, ch := 0, c.FontHeight
+	for r := rune(FIRST_GLYPH); r <= LAST_GLYPH; r++ {
+		w, h, err := font.SizeUTF8(string(r))
+		ck(err)
+		if w > cw {
+			cw = w
+		}
+		if h > ch {
+			ch = h
+		}
+	}
+
+	n := int(LAST_GLYPH - FIRST_GLYPH + 1)
+	aw := cw * ATLAS_COLUMNS
+	ah := ch * ((n + ATLAS_COLUMNS - 1) / ATLAS_COLUMNS)
+
+	scratch, err := sdl.CreateRGBSurfaceWithFormat(sdl.SWSURFACE, cw, ch, 32, sdl.PIXELFORMAT_ABGR8888)
+	ck(err)
+	defer scratch.Free()
+
+	texture, err := c.Renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STREAMING, aw, ah)
+	ck(err)
+
+	p, err := texture.Lock(nil)
+	ck(err)
+	for i := range p {
+		p[i] = 0
+	}
+
+	glyphs := make(map[rune]sdl.Rect)
+	for i := 0; i < n; i++ {
+		gr := rune(FIRST_GLYPH + i)
+		gx := (i % ATLAS_COLUMNS) * cw
+		gy := (i / ATLAS_COLUMNS) * ch
+
+		err = scratch.Lock()
+		ck(err)
+		s := scratch.Pixels()
+		for j := range s {
+			s[j] = 0
+		}
+		scratch.Unlock()
+
+		r, err := font.RenderUTF8BlendedEx(scratch, string(gr), sdlcolor.White)
+		ck(err)
+
+		err = scratch.Lock()
+		ck(err)
+		for y := 0; y < int(r.H) && y < ch; y++ {
+			for x := 0; x < int(r.W) && x < cw; x++ {
+				si := (y*cw + x) * 4
+				di := ((gy+y)*aw + gx + x) * 4
+				p[di] = s[si+2]
+				p[di+1] = s[si+1]
+				p[di+2] = s[si]
+				p[di+3] = s[si+3]
+			}
+		}
+		scratch.Unlock()
+
+		glyphs[gr] = sdl.Rect{int32(gx), int32(gy), r.W, r.H}
+	}
+	texture.Unlock()
+	texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+
+	c.Atlas = Atlas{
+		Texture: texture,
+		Glyphs:  glyphs,
+	}
+}
+
+func (c *Game) glyph(r rune) sdl.Rect {
+	g, found := c.Atlas.Glyphs[r]
+	if !found {
+		g = c.Atlas.Glyphs['?']
+	}
+	return g
+}
+
+// textWidth is how wide the widest line of text is in pixels.
+func (c *Game) textWidth(text string) int {
+	width := 0
+	for _, line := range strings.Split(text, "\n") {
+		w := 0
+		for _, r := range line {
+			w += int(c.glyph(r).W)
+		}
+		if w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+func (c *Game) drawText(pln, x, y int, col color.RGBA, format string, args ...interface{}) {
+	c.drawTextAligned(pln, ALIGN_LEFT, x, y, col, format, args...)
+}
+
+// drawTextAligned queues text to be drawn, x is where the left edge,
+// the center or the right edge of each line goes depending on align.
+func (c *Game) drawTextAligned(pln, align, x, y int, col color.RGBA, format string, args ...interface{}) {
+	x += c.Bound[pln].Min.X
+	y += c.Bound[pln].Min.Y
+	text := fmt.Sprintf(format, args...)
+
+	for _, line := range strings.Split(text, "\n") {
+		lx := x
+		switch align {
+		case ALIGN_CENTER:
+			lx -= c.textWidth(line) / 2
+		case ALIGN_RIGHT:
+			lx -= c.textWidth(line)
+		}
+
+		for _, r := range line {
+			g := c.glyph(r)
+			c.TextQueue = append(c.TextQueue, TextQuad{
+				Color: col,
+				Src:   g,
+				Dst:   sdl.Rect{int32(lx), int32(y), g.W, g.H},
+			})
+			lx += int(g.W)
+		}
+		y += c.FontHeight
+	}
+}
+
+// flushText draws all the queued text, grouped by color
+// so the atlas color only changes once per color.
+func (c *Game) flushText() {
+	q := c.TextQueue
+	if len(q) == 0 {
+		return
+	}
+
+	sort.SliceStable(q, func(i, j int) bool {
+		return colorKey(q[i].Color) < colorKey(q[j].Color)
+	})
+
+	re := c.Renderer
+	texture := c.Atlas.Texture
+	for i := range q {
+		if i == 0 || q[i].Color != q[i-1].Color {
+			col := q[i].Color
+			texture.SetColorMod(col.R, col.G, col.B)
+			texture.SetAlphaMod(col.A)
+		}
+		re.Copy(texture, &q[i].Src, &q[i].Dst)
+	}
+	c.TextQueue = q[:0]
+}
+
+func colorKey(c color.RGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}